@@ -0,0 +1,132 @@
+package confusablematcher
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Boundary reports whether there is a boundary between two runes. `Before` is -1 at the start of input string
+// and `After` is -1 at the end of it. A rune with a boundary between two of itself, such as whitespace, is a boundary character.
+type Boundary func(Before rune, After rune) bool
+
+// isWordRune Reports whether rune is part of a word: letters, digits, combining marks and connector punctuation
+func isWordRune(R rune) bool {
+	return R >= 0 && (unicode.IsLetter(R) || unicode.IsDigit(R) || unicode.IsMark(R) || unicode.Is(unicode.Pc, R))
+}
+
+// isIdeographic Reports whether rune forms a word of its own
+func isIdeographic(R rune) bool {
+	return unicode.In(R, unicode.Han, unicode.Hiragana)
+}
+
+// WordBoundary Boundary following Unicode word segmentation in simplified form: words are made of letters, digits,
+// combining marks and connector punctuation, ideographic characters are words of their own and there is a boundary
+// around every other character. Punctuation inside words, such as the apostrophe in "don't", separates words.
+func WordBoundary(Before rune, After rune) bool {
+	if !isWordRune(Before) || !isWordRune(After) {
+		return true
+	}
+	return isIdeographic(Before) || isIdeographic(After)
+}
+
+// SpaceBoundary Boundary next to whitespace and at both ends of input string
+func SpaceBoundary(Before rune, After rune) bool {
+	return Before < 0 || After < 0 || unicode.IsSpace(Before) || unicode.IsSpace(After)
+}
+
+// BoundarySet Returns boundary next to any of the runes of `Runes` and at both ends of input string
+func BoundarySet(Runes string) Boundary {
+	return func(Before rune, After rune) bool {
+		return Before < 0 || After < 0 || strings.ContainsRune(Runes, Before) || strings.ContainsRune(Runes, After)
+	}
+}
+
+// startsAt Reports whether a match may start at `Pos`. Ignore list entries before it are skipped, so that boundaries
+// are judged the same way as the matcher sees input, unless they contain a boundary character, which is a boundary itself.
+func (t *tracer) startsAt(Pos int) bool {
+	var after = firstRune(t.in[Pos:])
+	var before = t.in[:Pos]
+	for {
+		if t.boundary(lastRune(before), after) {
+			return true
+		}
+
+		var entry = ignoredSuffix(before, t.ignoreList)
+		if len(entry) == 0 {
+			return false
+		}
+		if t.hasBoundaryRune(entry) {
+			return true
+		}
+		before = before[:len(before)-len(entry)]
+	}
+}
+
+// endsAt Reports whether a match starting at `t.start` may end at `Pos`. Ignore list entries after it are skipped like in `startsAt`.
+func (t *tracer) endsAt(Pos int) bool {
+	var before = lastRune(t.in[t.start:Pos])
+	var after = t.in[Pos:]
+	for {
+		if t.boundary(before, firstRune(after)) {
+			return true
+		}
+
+		var entry = ignoredPrefix(after, t.ignoreList)
+		if len(entry) == 0 {
+			return false
+		}
+		if t.hasBoundaryRune(entry) {
+			return true
+		}
+		after = after[len(entry):]
+	}
+}
+
+// hasBoundaryRune Reports whether string contains a boundary character
+func (t *tracer) hasBoundaryRune(In string) bool {
+	for _, r := range In {
+		if t.boundary(r, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// ignoredSuffix Returns ignore list entry input ends with, empty if there is none
+func ignoredSuffix(In string, IgnoreList []string) string {
+	for _, el := range IgnoreList {
+		if len(el) != 0 && strings.HasSuffix(In, el) {
+			return el
+		}
+	}
+	return ""
+}
+
+// ignoredPrefix Returns ignore list entry input starts with, empty if there is none
+func ignoredPrefix(In string, IgnoreList []string) string {
+	for _, el := range IgnoreList {
+		if len(el) != 0 && strings.HasPrefix(In, el) {
+			return el
+		}
+	}
+	return ""
+}
+
+// firstRune Returns first rune of string, -1 if it is empty
+func firstRune(In string) rune {
+	if len(In) == 0 {
+		return -1
+	}
+	r, _ := utf8.DecodeRuneInString(In)
+	return r
+}
+
+// lastRune Returns last rune of string, -1 if it is empty
+func lastRune(In string) rune {
+	if len(In) == 0 {
+		return -1
+	}
+	r, _ := utf8.DecodeLastRuneInString(In)
+	return r
+}
@@ -0,0 +1,207 @@
+package confusablematcher
+
+import (
+	"context"
+	"runtime"
+	"time"
+)
+
+// Budget limits work done by a single search
+type Budget struct {
+	// Time Maximum duration of a single search, 0 for no limit. See `Matcher.IndexOfContext` about native searches.
+	Time time.Duration
+	// Steps Maximum number of matching steps of a single search, 0 for no limit. Every attempt to continue a match
+	// from an input position is a step. Native search cannot count its steps, so a search with a step limit runs in Go
+	// on the mirrored mappings.
+	Steps int
+}
+
+// searchLimit Stops a search running in Go once context is done, deadline passes or steps run out
+type searchLimit struct {
+	ctx      context.Context
+	deadline time.Time
+	steps    int
+	taken    int
+	err      error
+	// inGo Search has to run in Go, as it could not be interrupted natively
+	inGo bool
+}
+
+// backgroundSearches Semaphore of native searches which may be left running after the caller stopped waiting for them.
+// Native search cannot be interrupted, so this caps CPU spent on searches nobody waits for.
+var backgroundSearches = make(chan struct{}, runtime.GOMAXPROCS(0))
+
+// limitCheckInterval Number of steps between checks of context and deadline
+const limitCheckInterval = 1024
+
+// exceeded Counts a step and reports whether search has to give up, `nil` limit is never exceeded
+func (l *searchLimit) exceeded() bool {
+	if l == nil {
+		return false
+	}
+	if l.err != nil {
+		return true
+	}
+
+	l.taken++
+	if l.steps > 0 && l.taken > l.steps {
+		l.err = ErrBudgetExceeded
+		return true
+	}
+	if l.taken%limitCheckInterval == 0 {
+		if err := l.ctx.Err(); err != nil {
+			l.err = err
+			return true
+		}
+		if !l.deadline.IsZero() && time.Now().After(l.deadline) {
+			l.err = ErrBudgetExceeded
+			return true
+		}
+	}
+	return false
+}
+
+// stopped Reports whether search gave up
+func (l *searchLimit) stopped() bool {
+	return l != nil && l.err != nil
+}
+
+// native Reports whether search may run natively without being interrupted. Native search cannot count its steps,
+// so a search with a step limit has to run in Go.
+func (l *searchLimit) native() bool {
+	return l == nil || (l.steps <= 0 && !l.inGo)
+}
+
+// SetBudget Sets limits applied to every `IndexOf`, `IndexOfContext` and `Find` call on the matcher
+//
+// Parameters:
+//
+// - `B` : Budget, zero value for no limits
+func (m *Matcher) SetBudget(B Budget) error {
+	if m == nil {
+		return ErrClosed
+	}
+
+	m.lock.Lock()
+	m.budget = B
+	m.lock.Unlock()
+
+	return nil
+}
+
+// currentBudget Returns budget set with `SetBudget`, read once per call so that all of the call uses the same one
+func (m *Matcher) currentBudget() Budget {
+	if m == nil {
+		return Budget{}
+	}
+
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	return m.budget
+}
+
+// IndexOfContext Performs an indexOf operation which gives up once context is done or matcher's budget is exceeded.
+// Search running in Go checks context and budget while it runs and stops. Native search cannot be interrupted,
+// so once context is done or time runs out the caller gets the error right away, while native search keeps running
+// in the background until it finishes, keeping memory of the snapshot it searches alive meanwhile.
+// At most `runtime.GOMAXPROCS` such native searches run at once in the whole process, further ones run in Go
+// on the mirrored mappings, which follows documented semantics of the native matcher and stops in time, but is slower.
+//
+// Parameters:
+//
+// - `Ctx` : Context of the search
+// - `In` : Input string
+// - `Contains` : What input string should contain, aka the needle
+// - `MatchRepeating` : Should it match repeating substrings in the mapping (without consuming the 'contains' portion of operation)
+// - `StartIndex` : Starting index
+//
+// Returns:
+//
+// - Index and length, -1 and -1 if not found
+// - `Ctx.Err()` if context is done, `ErrBudgetExceeded` if budget was exceeded, or other error if operation could not be performed
+func (m *Matcher) IndexOfContext(Ctx context.Context, In string, Contains string, MatchRepeating bool, StartIndex int) (int, int, error) {
+	match, err := m.find(Ctx, m.currentBudget(), In, Contains, Options{MatchRepeating: MatchRepeating, StartIndex: StartIndex})
+	return match.Index, match.Length, err
+}
+
+// find Returns match with byte offsets only, within budget `B`
+func (m *Matcher) find(Ctx context.Context, B Budget, In string, Contains string, Options Options) (Match, error) {
+	s, err := m.Snapshot()
+	if err != nil {
+		return Match{Index: -1, Length: -1}, err
+	}
+	defer s.Release()
+
+	return s.find(Ctx, B, In, Contains, Options)
+}
+
+// IndexOfContext Performs an indexOf operation on this snapshot which gives up once context is done. See `Matcher.IndexOfContext`.
+func (s *Snapshot) IndexOfContext(Ctx context.Context, In string, Contains string, MatchRepeating bool, StartIndex int) (int, int, error) {
+	match, err := s.find(Ctx, Budget{}, In, Contains, Options{MatchRepeating: MatchRepeating, StartIndex: StartIndex})
+	return match.Index, match.Length, err
+}
+
+func (s *Snapshot) find(Ctx context.Context, B Budget, In string, Contains string, Options Options) (Match, error) {
+	var notFound = Match{Index: -1, Length: -1}
+	if err := Ctx.Err(); err != nil {
+		return notFound, err
+	}
+	if B.Time <= 0 && B.Steps <= 0 && Ctx.Done() == nil {
+		return s.indexOf(In, Contains, Options)
+	}
+
+	var limit = &searchLimit{ctx: Ctx, steps: B.Steps}
+	if B.Time > 0 {
+		limit.deadline = time.Now().Add(B.Time)
+	}
+
+	var inGo bool
+	s.lock.RLock()
+	if s.engine != nil {
+		inGo = s.engine.searchesInGo(In, Contains, Options, limit)
+	}
+	s.lock.RUnlock()
+	if inGo {
+		return s.indexAt(In, Contains, Options, ByteOffset(In, Options.StartIndex, Options.Unit), limit)
+	}
+
+	// Native search is left running in the background once the caller stops waiting for it
+	select {
+	case backgroundSearches <- struct{}{}:
+	default:
+		limit.inGo = true
+		return s.indexAt(In, Contains, Options, ByteOffset(In, Options.StartIndex, Options.Unit), limit)
+	}
+
+	var timeout <-chan time.Time
+	if B.Time > 0 {
+		var timer = time.NewTimer(B.Time)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	type result struct {
+		match Match
+		err   error
+	}
+	var done = make(chan result, 1)
+
+	s.acquire()
+	go func() {
+		defer func() { <-backgroundSearches }()
+		defer s.Release()
+
+		match, err := s.indexOf(In, Contains, Options)
+		done <- result{match, err}
+	}()
+
+	select {
+	case res := <-done:
+		return res.match, res.err
+	case <-Ctx.Done():
+		return notFound, Ctx.Err()
+	case <-timeout:
+		return notFound, ErrBudgetExceeded
+	}
+}
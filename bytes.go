@@ -0,0 +1,59 @@
+package confusablematcher
+
+import (
+	"context"
+	"unsafe"
+)
+
+// bytesString Returns string sharing memory with the slice. The slice must not be modified while the string is used.
+func bytesString(In []byte) string {
+	return unsafe.String(unsafe.SliceData(In), len(In))
+}
+
+// searchString Returns string sharing memory with the slice, unless the search may outlive the call
+// because of context or budget `B`, in which case the slice is copied. The search must use the same budget.
+func searchString(Ctx context.Context, B Budget, In []byte) string {
+	if Ctx.Done() != nil || B.Time > 0 {
+		return string(In)
+	}
+	return bytesString(In)
+}
+
+// IndexOfBytes Performs an indexOf operation on a byte slice without converting it to a string first.
+// Native matcher only accepts NUL terminated strings, so the slice cannot be passed to it directly: native search copies
+// every input into a reused buffer, which avoids allocations but not the copy. The pure Go matcher reads it in place.
+// `In` must not be modified until the call returns.
+//
+// Parameters:
+//
+// - `In` : Input bytes
+// - `Contains` : What input should contain, aka the needle
+// - `MatchRepeating` : Should it match repeating substrings in the mapping (without consuming the 'contains' portion of operation)
+// - `StartIndex` : Starting index
+//
+// Returns:
+//
+// - Index and length, -1 and -1 if not found
+// - `ErrBudgetExceeded` if search took longer than matcher's budget, or other error if operation could not be performed
+func (m *Matcher) IndexOfBytes(In []byte, Contains string, MatchRepeating bool, StartIndex int) (int, int, error) {
+	var budget = m.currentBudget()
+	match, err := m.find(context.Background(), budget, searchString(context.Background(), budget, In), Contains, Options{MatchRepeating: MatchRepeating, StartIndex: StartIndex})
+	return match.Index, match.Length, err
+}
+
+// FindBytes Finds a match in a byte slice like `Find`. `In` must not be modified until the call returns.
+func (m *Matcher) FindBytes(Ctx context.Context, In []byte, Contains string, Options Options) (Match, error) {
+	var budget = m.currentBudget()
+	return m.findMatch(Ctx, budget, searchString(Ctx, budget, In), Contains, Options)
+}
+
+// FindAllBytes Finds every match of the needle in a byte slice like `FindAll`. Native search copies input and needle into
+// a reused buffer like `IndexOfBytes` does, so only the returned matches are allocated. `In` must not be modified until the call returns.
+func (m *Matcher) FindAllBytes(In []byte, Contains string, Options Options) ([]Match, error) {
+	return m.FindAll(bytesString(In), Contains, Options)
+}
+
+// FindAnyBytes Finds every match of any of the needles in a byte slice like `FindAny`. `In` must not be modified until the call returns.
+func (m *Matcher) FindAnyBytes(In []byte, Needles *Needles, Options Options) ([]Match, error) {
+	return m.FindAny(bytesString(In), Needles, Options)
+}
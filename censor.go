@@ -0,0 +1,193 @@
+package confusablematcher
+
+import (
+	"bytes"
+	"io"
+	"unicode/utf8"
+)
+
+// CensorWriter masks matches of needles in data written through it, like `Censor` does in a string.
+// Data is passed on once no match can cover it any more, so up to `Options.MaxLength` of it is held back until `Close`.
+// CensorWriter must not be used from multiple goroutines at the same time.
+type CensorWriter struct {
+	w       io.Writer
+	scanner *Scanner
+	mask    []byte
+	pending []byte
+	// pendingBase Offset of `pending[0]` in the stream
+	pendingBase int
+	// masked Merged byte ranges of matches in the stream, ordered by start
+	masked [][2]int
+	err    error
+}
+
+// NewCensorWriter Creates a writer masking matches before passing data to `W`
+//
+// Parameters:
+//
+// - `W` : Writer receiving censored data
+// - `Matcher` : Matcher whose mappings and ignore list are used
+// - `Needles` : Needles returned by `CompileNeedles`, which must stay open while the writer is used
+// - `Options` : Search options, see `Matcher.NewScanner`. `MaxLength` is required.
+// - `Mask` : Rune to replace each rune of the match with
+//
+// Returns:
+//
+// - Censoring writer, `Close` must be called to pass on the rest of data. It does not close `W`.
+// - Error returned by `Matcher.NewScanner`
+func NewCensorWriter(W io.Writer, Matcher *Matcher, Needles *Needles, Options Options, Mask rune) (*CensorWriter, error) {
+	var c = &CensorWriter{w: W, mask: []byte(string(Mask))}
+
+	scanner, err := Matcher.NewScanner(Needles, Options, c.add)
+	if err != nil {
+		return nil, err
+	}
+	c.scanner = scanner
+	return c, nil
+}
+
+// Write Censors data and passes on as much of it as can no longer be part of a match
+func (c *CensorWriter) Write(P []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	c.pending = append(c.pending, P...)
+	if _, err := c.scanner.Write(P); err != nil {
+		c.err = err
+		return 0, err
+	}
+
+	// Scanner stops advancing once `Options.Max` matches were emitted, nothing else gets masked then
+	var end = c.scanner.base.Index
+	if c.scanner.completed {
+		end = c.pendingBase + len(c.pending)
+	}
+	if err := c.flush(end); err != nil {
+		return 0, err
+	}
+	return len(P), nil
+}
+
+// Close Censors and passes on the rest of data. Calling it again does nothing.
+func (c *CensorWriter) Close() error {
+	if c.err != nil {
+		if c.err == ErrClosed {
+			return nil
+		}
+		return c.err
+	}
+
+	if err := c.scanner.Close(); err != nil {
+		c.err = err
+		return err
+	}
+	if err := c.flush(c.pendingBase + len(c.pending)); err != nil {
+		return err
+	}
+	c.err = ErrClosed
+	return nil
+}
+
+// add Records range of a match emitted by the scanner
+func (c *CensorWriter) add(Match Match) error {
+	var start, end = Match.Index, Match.Index + Match.Length
+	if n := len(c.masked); n != 0 && start < c.masked[n-1][1] {
+		if end > c.masked[n-1][1] {
+			c.masked[n-1][1] = end
+		}
+		return nil
+	}
+
+	c.masked = append(c.masked, [2]int{start, end})
+	return nil
+}
+
+// flush Passes on data before stream offset `End`, masking recorded ranges
+func (c *CensorWriter) flush(End int) error {
+	var n = End - c.pendingBase
+	if n <= 0 {
+		return nil
+	}
+
+	var out = make([]byte, 0, n)
+	for x := 0; x < n; {
+		var pos = c.pendingBase + x
+		for len(c.masked) != 0 && c.masked[0][1] <= pos {
+			c.masked = c.masked[1:]
+		}
+
+		if len(c.masked) == 0 || c.masked[0][0] > pos {
+			var until = n
+			if len(c.masked) != 0 && c.masked[0][0]-c.pendingBase < until {
+				until = c.masked[0][0] - c.pendingBase
+			}
+			out = append(out, c.pending[x:until]...)
+			x = until
+			continue
+		}
+
+		_, width := utf8.DecodeRune(c.pending[x:n])
+		out = append(out, c.mask...)
+		x += width
+	}
+
+	c.pending = c.pending[:copy(c.pending, c.pending[n:])]
+	c.pendingBase = End
+
+	if _, err := c.w.Write(out); err != nil {
+		c.err = err
+		return err
+	}
+	return nil
+}
+
+// CensorReader masks matches of needles in data read through it, like `CensorWriter` does
+type CensorReader struct {
+	r   io.Reader
+	w   *CensorWriter
+	out bytes.Buffer
+	eof bool
+}
+
+// NewCensorReader Creates a reader masking matches in data read from `R`. Parameters are the same as of `NewCensorWriter`.
+func NewCensorReader(R io.Reader, Matcher *Matcher, Needles *Needles, Options Options, Mask rune) (*CensorReader, error) {
+	var c = &CensorReader{r: R}
+
+	w, err := NewCensorWriter(&c.out, Matcher, Needles, Options, Mask)
+	if err != nil {
+		return nil, err
+	}
+	c.w = w
+	return c, nil
+}
+
+// Read Reads censored data, holding back data which can still be part of a match until more is read
+func (c *CensorReader) Read(P []byte) (int, error) {
+	var chunk []byte
+	for c.out.Len() == 0 && !c.eof {
+		if chunk == nil {
+			chunk = make([]byte, 32*1024)
+		}
+
+		n, err := c.r.Read(chunk)
+		if n > 0 {
+			if _, werr := c.w.Write(chunk[:n]); werr != nil {
+				return 0, werr
+			}
+		}
+		if err == io.EOF {
+			c.eof = true
+			if cerr := c.w.Close(); cerr != nil {
+				return 0, cerr
+			}
+		} else if err != nil {
+			return 0, err
+		}
+	}
+
+	if c.out.Len() == 0 {
+		return 0, io.EOF
+	}
+	return c.out.Read(P)
+}
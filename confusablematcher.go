@@ -1,12 +1,10 @@
 package confusablematcher
 
-// #include "ConfusableMatcher/Export.h"
-// #include <stdlib.h>
-// #cgo LDFLAGS: -L. -lconfusablematcher -lstdc++
-import "C"
 import (
+	"context"
+	"errors"
+	"runtime"
 	"sync"
-	"unsafe"
 )
 
 // KeyValue Key and value structure
@@ -15,11 +13,23 @@ type KeyValue struct {
 	Value string
 }
 
-// CMHandle confusable matcher instance
+// Matcher confusable matcher instance. A single `*Matcher` may be shared between goroutines.
+// Searches run concurrently with each other and with changes. Changes are made one at a time: mapping changes are made
+// in place if nothing else uses the current snapshot, otherwise they build a changed copy of it and publish it, while
+// ignore list changes always publish a copy sharing native matcher. Searches already running finish on the previous one.
+type Matcher struct {
+	current *Snapshot
+	budget  Budget
+	lock    sync.RWMutex
+	// write Serializes changes, held while the next snapshot is built
+	write sync.Mutex
+}
+
+// CMHandle confusable matcher handle
+//
+// Deprecated: Use `*Matcher` returned by `New` instead.
 type CMHandle struct {
-	matcher    C.CMHandle
-	ignoreList C.CMListHandle
-	lock       sync.Mutex
+	m *Matcher
 }
 
 // MappingResponse describes result returned by `AddMapping`
@@ -40,54 +50,200 @@ const (
 	InvalidValue MappingResponse = 5
 )
 
-// InitConfusableMatcher Initializes new confusable matcher. If this instance is not used any more, `FreeConfusableMatcher` function must be called.
+// New Initializes new confusable matcher. If this instance is not used any more, `Close` method must be called.
+// Native matcher cannot be given strings containing 0x00 byte, so such mappings and ignore list entries are kept in Go only.
+// Searches whose needle contains 0x00 byte, or whose input contains it while such a mapping or entry exists,
+// run in Go on the mirrored mappings, which follows documented semantics of the native matcher but performs differently.
+// Other searches run natively.
 //
 // Parameters:
 //
-// - `Map` : InputMap Input key to value mapping
+// - `InputMap` : Input key to value mapping
 // - `AddDefaultValues` : Whether to add default values or not ([a-z] -> [A-Z], [A-Z] -> [A-Z], [0-9] -> [0-9])
 //
 // Returns:
 //
-// - Handle to confusable matcher
-func InitConfusableMatcher(InputMap []KeyValue, AddDefaultValues bool) CMHandle {
-	var cmMap C.CMMap
-
-	var tmp C.CMKV
-	var structSz = int(unsafe.Sizeof(tmp))
-	cmMap.Kv = (*C.CMKV)(C.malloc((C.ulong)(len(InputMap) * structSz * 5)))
-	defer C.free(unsafe.Pointer(cmMap.Kv))
+// - Confusable matcher
+// - `ErrInitFailed` if native matcher could not be constructed
+func New(InputMap []KeyValue, AddDefaultValues bool) (*Matcher, error) {
+	s, err := NewSnapshot(InputMap, AddDefaultValues)
+	if err != nil {
+		return nil, err
+	}
 
-	for x, el := range InputMap {
-		var cmKV C.CMKV
-		cmKV.Key = C.CString(el.Key)
-		defer C.free(unsafe.Pointer(cmKV.Key))
-		cmKV.Value = C.CString(el.Value)
-		defer C.free(unsafe.Pointer(cmKV.Value))
+	var m = &Matcher{current: s}
+	runtime.SetFinalizer(m, (*Matcher).finalize)
+	return m, nil
+}
 
-		var ptr = unsafe.Pointer(uintptr(unsafe.Pointer(cmMap.Kv)) + uintptr(x*structSz))
-		*((*C.CMKV)(ptr)) = cmKV
+// Snapshot Returns snapshot currently used by the matcher. `Release` must be called on it once it is not used any more.
+func (m *Matcher) Snapshot() (*Snapshot, error) {
+	if m == nil {
+		return nil, ErrClosed
 	}
 
-	cmMap.Size = C.uint(len(InputMap))
-
-	var handle CMHandle
+	var s *Snapshot
+	m.lock.RLock()
+	{
+		s = m.current
+		if s != nil {
+			s.acquire()
+		}
+	}
+	m.lock.RUnlock()
 
-	var empty []string
-	SetIgnoreList(&handle, empty)
-	handle.matcher = C.InitConfusableMatcher(cmMap, (C.bool)(AddDefaultValues))
-	return handle
+	if s == nil {
+		return nil, ErrClosed
+	}
+	return s, nil
 }
 
-// FreeConfusableMatcher Frees confusable matcher. Passed confusable matcher handle cannot be used after this method is called.
-//
+// Swap Atomically publishes new snapshot into the matcher, keeping matcher's ignore list.
+// If the snapshot has a different ignore list, a copy of it sharing its native matcher and searching with matcher's
+// ignore list is published instead, so the snapshot itself is never changed and may be shared.
+// Searches already running finish on the previous snapshot, which is freed once they are done.
+// The matcher takes over reference held by the caller.
 //
 // Parameters:
 //
-// - `Matcher` : Confusable matcher handle
-func FreeConfusableMatcher(Handle CMHandle) {
-	C.FreeIgnoreList(Handle.ignoreList)
-	C.FreeConfusableMatcher(Handle.matcher)
+// - `New` : Snapshot returned by `NewSnapshot` or `Matcher.Snapshot`
+//
+// Returns:
+//
+// - `ErrClosed` or `ErrInitFailed` if snapshot was not published, in which case the caller keeps its reference
+func (m *Matcher) Swap(New *Snapshot) error {
+	if m == nil {
+		return ErrClosed
+	}
+
+	m.write.Lock()
+	defer m.write.Unlock()
+
+	current, err := m.Snapshot()
+	if err != nil {
+		return err
+	}
+	var list = current.ignoreList()
+	current.Release()
+
+	var next = New
+	if !equalStrings(New.ignoreList(), list) {
+		if next, err = New.withIgnoreList(list); err != nil {
+			return err
+		}
+	}
+
+	if err := m.publish(next); err != nil {
+		if next != New {
+			next.Release()
+		}
+		return err
+	}
+	if next != New {
+		New.Release()
+	}
+	return nil
+}
+
+// update Changes mappings of the current snapshot with `fn`. If nothing but the matcher holds the snapshot, it is changed
+// in place, otherwise a copy of it is changed and published unless `fn` fails. `fn` must not leave a change half made.
+// Snapshots already handed out are never changed. Must not be called with `m.write` held.
+func (m *Matcher) update(fn func(Next *Snapshot) error) error {
+	if m == nil {
+		return ErrClosed
+	}
+
+	m.write.Lock()
+	defer m.write.Unlock()
+
+	// Nothing can take hold of the current snapshot while `m.lock` is held, searches starting meanwhile wait for the change
+	m.lock.Lock()
+	if m.current == nil {
+		m.lock.Unlock()
+		return ErrClosed
+	}
+	if m.current.exclusive() {
+		var err = fn(m.current)
+		m.lock.Unlock()
+		return err
+	}
+	m.lock.Unlock()
+
+	return m.replace(func(Current *Snapshot) (*Snapshot, error) {
+		next, err := Current.clone()
+		if err != nil {
+			return nil, err
+		}
+		if err := fn(next); err != nil {
+			next.Release()
+			return nil, err
+		}
+		return next, nil
+	})
+}
+
+// replace Publishes snapshot which `fn` builds from the current one. Must be called with `m.write` held.
+func (m *Matcher) replace(fn func(Current *Snapshot) (*Snapshot, error)) error {
+	current, err := m.Snapshot()
+	if err != nil {
+		return err
+	}
+	next, err := fn(current)
+	current.Release()
+	if err != nil {
+		return err
+	}
+
+	if err := m.publish(next); err != nil {
+		next.Release()
+		return err
+	}
+	return nil
+}
+
+// publish Replaces the current snapshot, taking over reference to `Next`
+func (m *Matcher) publish(Next *Snapshot) error {
+	var old *Snapshot
+	m.lock.Lock()
+	{
+		if m.current == nil {
+			m.lock.Unlock()
+			return ErrClosed
+		}
+		old = m.current
+		m.current = Next
+	}
+	m.lock.Unlock()
+
+	old.Release()
+	return nil
+}
+
+// Close Frees confusable matcher. Operations called afterwards return `ErrClosed`, calling `Close` again does nothing.
+// Searches already running finish before native memory is freed.
+func (m *Matcher) Close() error {
+	if m == nil {
+		return nil
+	}
+
+	var old *Snapshot
+	m.lock.Lock()
+	{
+		old = m.current
+		m.current = nil
+	}
+	m.lock.Unlock()
+
+	if old != nil {
+		runtime.SetFinalizer(m, nil)
+		old.Release()
+	}
+	return nil
+}
+
+func (m *Matcher) finalize() {
+	reportLeak("Matcher")
+	m.Close()
 }
 
 // SetIgnoreList sets an array of strings to ignore when performing an indexOf operation.
@@ -96,91 +252,305 @@ func FreeConfusableMatcher(Handle CMHandle) {
 // Parameters:
 //
 // - `In` : Input array of strings to set to ignore
-func SetIgnoreList(Handle *CMHandle, In []string) {
-	var tmp *C.char
-	var ptrSz = int(unsafe.Sizeof(&tmp))
-	var list = (**C.char)(C.malloc((C.ulong)(len(In) * ptrSz)))
-	defer C.free(unsafe.Pointer(list))
+//
+// Returns:
+//
+// - `ErrInitFailed` if native ignore list could not be constructed, previous list is kept in that case
+func (m *Matcher) SetIgnoreList(In []string) error {
+	return m.updateIgnoreList(func([]string) []string {
+		return append([]string(nil), In...)
+	})
+}
 
-	for x, el := range In {
-		var str = C.CString(el)
-		defer C.free(unsafe.Pointer(str))
+// IndexOf Performs an indexOf operation using specified mapping and ignore list
+//
+// Parameters:
+//
+// - `In` : Input string
+// - `Contains` : What input string should contain, aka the needle
+// - `MatchRepeating` : Should it match repeating substrings in the mapping (without consuming the 'contains' portion of operation)
+// - `StartIndex` : Starting index
+//
+// Returns:
+//
+// - Index and length, -1 and -1 if not found
+// - `ErrBudgetExceeded` if search took longer than matcher's budget, or other error if operation could not be performed
+func (m *Matcher) IndexOf(In string, Contains string, MatchRepeating bool, StartIndex int) (int, int, error) {
+	return m.IndexOfContext(context.Background(), In, Contains, MatchRepeating, StartIndex)
+}
+
+// Find Finds a match of the needle, giving up once context is done or matcher's budget is exceeded like `IndexOfContext`
+//
+// Parameters:
+//
+// - `Ctx` : Context of the search
+// - `In` : Input string
+// - `Contains` : What input string should contain, aka the needle
+// - `Options` : Search options, zero value finds first match from the start of input string like `IndexOf`
+//
+// Returns:
+//
+// - Match with offsets in every unit, index and length of -1 if not found
+// - `Ctx.Err()` if context is done, `ErrBudgetExceeded` if budget was exceeded, or other error if operation could not be performed
+func (m *Matcher) Find(Ctx context.Context, In string, Contains string, Options Options) (Match, error) {
+	return m.findMatch(Ctx, m.currentBudget(), In, Contains, Options)
+}
 
-		var ptr = unsafe.Pointer(uintptr(unsafe.Pointer(list)) + uintptr(x*ptrSz))
-		*((**C.char)(ptr)) = str
+// findMatch Returns match with offsets in every unit, within budget `B`
+func (m *Matcher) findMatch(Ctx context.Context, B Budget, In string, Contains string, Options Options) (Match, error) {
+	match, err := m.find(Ctx, B, In, Contains, Options)
+	if err == nil && match.Index >= 0 {
+		var matches = []Match{match}
+		fillOffsets(In, matches)
+		match = matches[0]
 	}
+	return match, err
+}
 
-	(*Handle).lock.Lock()
-	{
-		if (*Handle).ignoreList != nil {
-			C.FreeIgnoreList((*Handle).ignoreList)
-		}
-		(*Handle).ignoreList = C.ConstructIgnoreList(list, (C.int)(len(In)))
+// FindAll Finds every match of the needle in input string, crossing into native code only once
+//
+// Parameters:
+//
+// - `In` : Input string
+// - `Contains` : What input string should contain, aka the needle
+// - `Options` : Search options, zero value finds all non-overlapping matches from the start of input string
+//
+// Returns:
+//
+// - Matches ordered by index, from last to first with `Backward` direction. `nil` if nothing was found
+// - `ErrOutOfMemory` if native code could not allocate memory for matches, or other error if operation could not be performed
+func (m *Matcher) FindAll(In string, Contains string, Options Options) ([]Match, error) {
+	s, err := m.Snapshot()
+	if err != nil {
+		return nil, err
 	}
-	(*Handle).lock.Unlock()
+	defer s.Release()
+
+	return s.FindAll(In, Contains, Options)
 }
 
-// IndexOf Performs an indexOf operation using specified mapping and ignore list
+// FindAny Finds every match of any of the compiled needles in input string, crossing into native code only once
+//
+// Parameters:
+//
+// - `In` : Input string
+// - `Needles` : Needles returned by `CompileNeedles`
+// - `Options` : Search options. Needles are searched in order, so `Max` keeps matches of earlier needles.
+//
+// Returns:
+//
+// - Matches ordered by index, from last to first with `Backward` direction. `Match.Needle` holds index of the needle which matched.
+// `nil` if nothing was found
+// - `ErrOutOfMemory` if native code could not allocate memory for matches, or other error if operation could not be performed
+func (m *Matcher) FindAny(In string, Needles *Needles, Options Options) ([]Match, error) {
+	s, err := m.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	defer s.Release()
+
+	return s.FindAny(In, Needles, Options)
+}
+
+// Explain Finds a match and describes which mappings and ignore list entries produced it
 //
 // Parameters:
 //
-// - `Matcher` : Handle to confusable matcher (returned by InitConfusableMatcher)
 // - `In` : Input string
 // - `Contains` : What input string should contain, aka the needle
-// - `MatchRepeating` : Should it match repeating substrings in the mapping (without consuming the 'contains' portion of operation)
-// - `StartIndex` : Starting index
+// - `Options` : Search options, see `Find`
 //
 // Returns:
 //
-// - Index and length
-func IndexOf(Handle CMHandle, In string, Contains string, MatchRepeating bool, StartIndex int) (int, int) {
-	var inPtr = C.CString(In)
-	defer C.free(unsafe.Pointer(inPtr))
-	var containsPtr = C.CString(Contains)
-	defer C.free(unsafe.Pointer(containsPtr))
+// - Explanation of the match, `nil` if not found
+// - `ErrNoExplanation` if match could not be reconstructed, or other error if operation could not be performed
+func (m *Matcher) Explain(In string, Contains string, Options Options) (*Explanation, error) {
+	s, err := m.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	defer s.Release()
 
-	var ret uint64
-	Handle.lock.Lock()
-	{
-		ret = uint64(C.StringIndexOf(Handle.matcher, inPtr, containsPtr, (C.bool)(MatchRepeating), (C.int)(StartIndex), Handle.ignoreList))
+	return s.Explain(In, Contains, Options)
+}
+
+// Mappings Returns key to value mappings currently loaded in the matcher, including default values.
+// Passing them to `New` with `AddDefaultValues` unset gives a matcher which matches the same way.
+//
+// Returns:
+//
+// - Mappings ordered by first byte of the key, mappings sharing first byte in the order they were added. `nil` if matcher is closed
+func (m *Matcher) Mappings() []KeyValue {
+	s, err := m.Snapshot()
+	if err != nil {
+		return nil
 	}
-	Handle.lock.Unlock()
+	defer s.Release()
 
-	return int(int32(ret & 0xFFFFFFFF)), int(int32(ret >> 32))
+	return s.Mappings()
 }
 
-// AddMapping Adds a new key to value mapping into existing confusable matcher
+// MappingsForKey Returns values mapped to the key
+//
+// Parameters:
+//
+// - `Key` : Input key
+//
+// Returns:
+//
+// - Values in the order they were added, `nil` if there are none or matcher is closed
+func (m *Matcher) MappingsForKey(Key string) []string {
+	s, err := m.Snapshot()
+	if err != nil {
+		return nil
+	}
+	defer s.Release()
+
+	return s.MappingsForKey(Key)
+}
+
+// Len Returns number of key to value mappings currently loaded in the matcher, 0 if matcher is closed
+func (m *Matcher) Len() int {
+	s, err := m.Snapshot()
+	if err != nil {
+		return 0
+	}
+	defer s.Release()
+
+	return s.Len()
+}
+
+// AddMapping Adds a new key to value mapping into existing confusable matcher.
+// The change is made in place unless searches or held snapshots use the current snapshot, in which case a new native matcher
+// is built off to the side from all mappings before it is published, so many changes are better made at once
+// with `AddMappings` or `Begin`. Mapping containing 0x00 byte is kept in Go only, see `New` for searches it affects.
 //
 // Parameters:
 //
-// - `Matcher` : Handle to confusable matcher (returned by InitConfusableMatcher)
 // - `Key` : Input key
 // - `Value` : Input value
 // - `CheckValueDuplicate` : Check if key and value combination already exists
 //
-// - Operation result
-func AddMapping(Handle CMHandle, Key string, Value string, CheckValueDuplicate bool) MappingResponse {
-	var keyPtr = C.CString(Key)
-	defer C.free(unsafe.Pointer(keyPtr))
-	var valPtr = C.CString(Value)
-	defer C.free(unsafe.Pointer(valPtr))
-
-	return MappingResponse(C.AddMapping(Handle.matcher, keyPtr, valPtr, C.bool(CheckValueDuplicate)))
+// Returns:
+//
+// - `*MappingError` if mapping was not added
+func (m *Matcher) AddMapping(Key string, Value string, CheckValueDuplicate bool) error {
+	return m.update(func(Next *Snapshot) error {
+		return Next.addMapping(Key, Value, CheckValueDuplicate)
+	})
 }
 
-// RemoveMapping Removes an existing key to value mapping from confusable matcher
+// RemoveMapping Removes an existing key to value mapping from confusable matcher. See `AddMapping` about the cost of a change.
 //
 // Parameters:
 //
 // - `Key` : Input key
 // - `Value` : Input value
 //
-// - If operation was successful or not
-func RemoveMapping(Handle CMHandle, Key string, Value string) bool {
-	var keyPtr = C.CString(Key)
-	defer C.free(unsafe.Pointer(keyPtr))
-	var valPtr = C.CString(Value)
-	defer C.free(unsafe.Pointer(valPtr))
+// Returns:
+//
+// - `ErrNotFound` if key and value combination does not exist
+func (m *Matcher) RemoveMapping(Key string, Value string) error {
+	return m.update(func(Next *Snapshot) error {
+		return Next.removeMapping(Key, Value)
+	})
+}
 
-	return bool(C.RemoveMapping(Handle.matcher, keyPtr, valPtr))
+// AddMappings Adds key to value mappings into existing confusable matcher in a single native call,
+// without searches seeing only some of them
+//
+// Parameters:
+//
+// - `Mappings` : Key to value mappings, added in order
+// - `CheckValueDuplicate` : Check if key and value combination already exists, including earlier mappings of the batch
+// - `AllOrNothing` : Add nothing if any of the mappings would not be added
+//
+// Returns:
+//
+// - Result of every mapping. If nothing was added because of `AllOrNothing`, results are the ones adding would give
+// - `*MappingError` of first failing mapping if nothing was added because of `AllOrNothing`, or other error if operation could not be performed
+func (m *Matcher) AddMappings(Mappings []KeyValue, CheckValueDuplicate bool, AllOrNothing bool) ([]MappingResponse, error) {
+	var ret []MappingResponse
+	var err = m.update(func(Next *Snapshot) error {
+		var err error
+		ret, err = Next.addMappings(Mappings, CheckValueDuplicate, AllOrNothing)
+		return err
+	})
+	return ret, err
+}
+
+// RemoveMappings Removes key to value mappings from confusable matcher in a single native call,
+// without searches seeing only some of them removed
+//
+// Parameters:
+//
+// - `Mappings` : Key to value mappings, removed in order
+// - `AllOrNothing` : Remove nothing if any of the mappings does not exist
+//
+// Returns:
+//
+// - Whether each mapping was removed. If nothing was removed because of `AllOrNothing`, results are the ones removing would give
+// - `ErrNotFound` if nothing was removed because of `AllOrNothing`, or other error if operation could not be performed
+func (m *Matcher) RemoveMappings(Mappings []KeyValue, AllOrNothing bool) ([]bool, error) {
+	var ret []bool
+	var err = m.update(func(Next *Snapshot) error {
+		var err error
+		ret, err = Next.removeMappings(Mappings, AllOrNothing)
+		return err
+	})
+	return ret, err
+}
+
+// InitConfusableMatcher Initializes new confusable matcher. If this instance is not used any more, `FreeConfusableMatcher` function must be called.
+// Panics if native matcher could not be constructed.
+//
+// Deprecated: Use `New` instead.
+func InitConfusableMatcher(InputMap []KeyValue, AddDefaultValues bool) CMHandle {
+	m, err := New(InputMap, AddDefaultValues)
+	if err != nil {
+		panic(err)
+	}
+
+	return CMHandle{m}
+}
+
+// FreeConfusableMatcher Frees confusable matcher. Passed confusable matcher handle cannot be used after this method is called.
+//
+// Deprecated: Use `Matcher.Close` instead.
+func FreeConfusableMatcher(Handle CMHandle) {
+	Handle.m.Close()
+}
+
+// SetIgnoreList sets an array of strings to ignore when performing an indexOf operation.
+//
+// Deprecated: Use `Matcher.SetIgnoreList` instead.
+func SetIgnoreList(Handle *CMHandle, In []string) {
+	Handle.m.SetIgnoreList(In)
+}
+
+// IndexOf Performs an indexOf operation using specified mapping and ignore list
+//
+// Deprecated: Use `Matcher.IndexOf` instead.
+func IndexOf(Handle CMHandle, In string, Contains string, MatchRepeating bool, StartIndex int) (int, int) {
+	index, length, _ := Handle.m.IndexOf(In, Contains, MatchRepeating, StartIndex)
+	return index, length
+}
+
+// AddMapping Adds a new key to value mapping into existing confusable matcher
+//
+// Deprecated: Use `Matcher.AddMapping` instead.
+func AddMapping(Handle CMHandle, Key string, Value string, CheckValueDuplicate bool) MappingResponse {
+	var mErr *MappingError
+	if errors.As(Handle.m.AddMapping(Key, Value, CheckValueDuplicate), &mErr) {
+		return mErr.Response
+	}
+
+	return Success
+}
+
+// RemoveMapping Removes an existing key to value mapping from confusable matcher
+//
+// Deprecated: Use `Matcher.RemoveMapping` instead.
+func RemoveMapping(Handle CMHandle, Key string, Value string) bool {
+	return Handle.m.RemoveMapping(Key, Value) == nil
 }
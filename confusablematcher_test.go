@@ -1,9 +1,19 @@
 package confusablematcher
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -488,58 +498,1578 @@ func Test15(t *testing.T) {
 
 func Test16(t *testing.T) {
 	var inMap []KeyValue
-	var running = true
-	var matcher = InitConfusableMatcher(inMap, true)
+	matcher, err := New(inMap, true)
+	assert.NoError(t, err)
+	var wg sync.WaitGroup
+
+	for x := 0; x < 8; x++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 10000; i++ {
+				index, _, err := matcher.IndexOf("ASD", "ZXC", false, 0)
+				assert.NoError(t, err)
+				assert.True(t, index == -1 || index == 0)
+			}
+		}()
+	}
 
+	wg.Add(2)
 	go func() {
-		for running {
-			IndexOf(matcher, "ASD", "ZXC", false, 0)
+		defer wg.Done()
+		for i := 0; i < 10000; i++ {
+			matcher.AddMapping("Z", "A", false)
+			matcher.RemoveMapping("Z", "A")
 		}
 	}()
 
 	go func() {
-		for running {
-			AddMapping(matcher, "Z", "A", false)
-			RemoveMapping(matcher, "Z", "A")
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			assert.NoError(t, matcher.SetIgnoreList([]string{"_"}))
 		}
 	}()
 
-	time.Sleep(time.Second * 10)
-
-	running = false
+	wg.Wait()
+	matcher.Close()
 }
 
 func Test17(t *testing.T) {
 	var inMap []KeyValue
 	inMap = append(inMap, KeyValue{"N", "/\\/"})
-	var ignoreList []string
-	var matcher = InitConfusableMatcher(inMap, true)
-	var running = true
-	var lock sync.Mutex
+	matcher, err := New(inMap, true)
+	assert.NoError(t, err)
+	var wg sync.WaitGroup
+
+	for x := 0; x < 8; x++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 10000; i++ {
+				index, length, err := matcher.IndexOf("/\\/", "N", false, 0)
+				assert.NoError(t, err)
+				assert.Equal(t, 0, index)
+				assert.Equal(t, 3, length)
+			}
+		}()
+	}
 
+	wg.Add(1)
 	go func() {
-		for running {
-			lock.Lock()
-			{
-				IndexOf(matcher, "/\\/", "N", false, 0)
-			}
-			lock.Unlock()
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			s, err := NewSnapshot(inMap, true)
+			assert.NoError(t, err)
+			assert.NoError(t, matcher.Swap(s))
+			assert.NoError(t, matcher.SetIgnoreList(nil))
 		}
 	}()
 
+	wg.Wait()
+	matcher.Close()
+}
+
+func TestSnapshotOutlivesSwap(t *testing.T) {
+	var inMap []KeyValue
+	inMap = append(inMap, KeyValue{"N", "/\\/"})
+
+	matcher, err := New(inMap, true)
+	assert.NoError(t, err)
+	assert.NoError(t, matcher.SetIgnoreList([]string{"_"}))
+
+	old, err := matcher.Snapshot()
+	assert.NoError(t, err)
+	s, err := NewSnapshot(nil, true)
+	assert.NoError(t, err)
+	assert.NoError(t, matcher.Swap(s))
+
+	index, length, err := old.IndexOf("/\\/_ICE", "NICE", false, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, index)
+	assert.Equal(t, 7, length)
+	old.Release()
+
+	index, length, err = matcher.IndexOf("/\\/_ICE", "NICE", false, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, -1, index)
+	assert.Equal(t, -1, length)
+
+	index, length, err = matcher.IndexOf("N_ICE", "NICE", false, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, index)
+	assert.Equal(t, 5, length)
+
+	matcher.Close()
+}
+
+func TestSnapshotImmutable(t *testing.T) {
+	matcher, err := New([]KeyValue{{"N", "/\\/"}}, true)
+	assert.NoError(t, err)
+	defer matcher.Close()
+	assert.NoError(t, matcher.SetIgnoreList([]string{"_"}))
+
+	held, err := matcher.Snapshot()
+	assert.NoError(t, err)
+	defer held.Release()
+
+	assert.NoError(t, matcher.AddMapping("C", "(", true))
+	assert.NoError(t, matcher.RemoveMapping("N", "/\\/"))
+	assert.NoError(t, matcher.SetIgnoreList([]string{"-"}))
+	var tx = matcher.Begin()
+	tx.AddMapping("E", "3", true)
+	assert.NoError(t, tx.Commit())
+
+	// Held snapshot keeps the view it had when it was taken
+	index, length, err := held.IndexOf("/\\/_ICE", "NICE", false, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0, 7}, []int{index, length})
+	index, _, err = held.IndexOf("N-I(3", "NICE", false, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, -1, index)
+	assert.Equal(t, []string{"_"}, held.ignoreList())
+	assert.Equal(t, 1+26*2+10, held.Len())
+
+	index, length, err = matcher.IndexOf("N-I(3", "NICE", false, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0, 5}, []int{index, length})
+	assert.Equal(t, 26*2+10+2, matcher.Len())
+
+	// Swapping a snapshot shared with another matcher keeps its ignore list for the other one
+	other, err := New(nil, true)
+	assert.NoError(t, err)
+	defer other.Close()
+	shared, err := matcher.Snapshot()
+	assert.NoError(t, err)
+	assert.NoError(t, other.Swap(shared))
+	assert.Equal(t, []string{}, other.IgnoreList())
+	assert.Equal(t, []string{"-"}, matcher.IgnoreList())
+
+	index, _, err = other.IndexOf("N-I(3", "NICE", false, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, -1, index)
+	index, length, err = other.IndexOf("NI(3", "NICE", false, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0, 4}, []int{index, length})
+	index, length, err = matcher.IndexOf("N-I(3", "NICE", false, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0, 5}, []int{index, length})
+}
+
+func TestIgnoreListSharesMatcher(t *testing.T) {
+	matcher, err := New([]KeyValue{{"N", "/\\/"}}, true)
+	assert.NoError(t, err)
+	defer matcher.Close()
+
+	before, err := matcher.Snapshot()
+	assert.NoError(t, err)
+	defer before.Release()
+	assert.NoError(t, matcher.SetIgnoreList([]string{"_"}))
+	assert.NoError(t, matcher.AddIgnore("-"))
+
+	after, err := matcher.Snapshot()
+	assert.NoError(t, err)
+	assert.True(t, before.engine.core == after.engine.core)
+	assert.Empty(t, before.ignoreList())
+	after.Release()
+
+	// Swapped snapshot is published with matcher's ignore list without building its mappings again
+	s, err := NewSnapshot(nil, true)
+	assert.NoError(t, err)
+	var core = s.engine.core
+	assert.NoError(t, matcher.Swap(s))
+
+	after, err = matcher.Snapshot()
+	assert.NoError(t, err)
+	assert.True(t, core == after.engine.core)
+	assert.Equal(t, []string{"_", "-"}, after.ignoreList())
+	after.Release()
+
+	index, length, err := matcher.IndexOf("N_I-CE", "NICE", false, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0, 6}, []int{index, length})
+}
+
+func TestChangesInPlace(t *testing.T) {
+	matcher, err := New(nil, true)
+	assert.NoError(t, err)
+	defer matcher.Close()
+
+	// Mappings of a snapshot nothing else holds are changed without building native matcher again
+	var current = matcher.current
+	for x := 0; x < 100; x++ {
+		assert.NoError(t, matcher.AddMapping("A", strconv.Itoa(x), true))
+	}
+	assert.NoError(t, matcher.RemoveMapping("A", "0"))
+	assert.True(t, current == matcher.current)
+	assert.Equal(t, 26*2+10+99, matcher.Len())
+
+	held, err := matcher.Snapshot()
+	assert.NoError(t, err)
+	assert.NoError(t, matcher.AddMapping("B", "8", true))
+	assert.False(t, held == matcher.current)
+	assert.Empty(t, held.MappingsForKey("B")[2:])
+	held.Release()
+
+	// Snapshot sharing native matcher with another one is copied as well
+	assert.NoError(t, matcher.SetIgnoreList([]string{"_"}))
+	other, err := matcher.Snapshot()
+	assert.NoError(t, err)
+	shared, err := other.withIgnoreList(nil)
+	assert.NoError(t, err)
+	other.Release()
+	assert.NoError(t, matcher.AddMapping("C", "(", true))
+	assert.Equal(t, []string{"C", "c"}, shared.MappingsForKey("C"))
+	shared.Release()
+
+	index, length, err := matcher.IndexOf("99_88(", "ABBC", false, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0, 6}, []int{index, length})
+}
+
+func TestMatcher(t *testing.T) {
+	var inMap []KeyValue
+
+	inMap = append(inMap, KeyValue{"N", "/\\/"})
+
+	matcher, err := New(inMap, true)
+	assert.NoError(t, err)
+	assert.NoError(t, matcher.SetIgnoreList([]string{"_"}))
+
+	index, length, err := matcher.IndexOf("/\\/_ICE", "NICE", false, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, index)
+	assert.Equal(t, 7, length)
+
+	assert.NoError(t, matcher.AddMapping("C", "(", true))
+	assert.True(t, errors.Is(matcher.AddMapping("C", "(", true), ErrAlreadyExists))
+
+	index, length, err = matcher.IndexOf("/\\/I(E", "NICE", false, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, index)
+	assert.Equal(t, 6, length)
+
+	assert.NoError(t, matcher.RemoveMapping("C", "("))
+	assert.Equal(t, ErrNotFound, matcher.RemoveMapping("C", "("))
+
+	matcher.Close()
+}
+
+func TestHandleCopiesShareMatcher(t *testing.T) {
+	var inMap []KeyValue
+
+	var handle = InitConfusableMatcher(inMap, true)
+	var handleCopy = handle
+
+	AddMapping(handleCopy, "N", "/\\/", false)
+	SetIgnoreList(&handleCopy, []string{"_"})
+
+	index, length := IndexOf(handle, "/\\/_ICE", "NICE", false, 0)
+	assert.Equal(t, 0, index)
+	assert.Equal(t, 7, length)
+
+	FreeConfusableMatcher(handle)
+}
+
+func TestMappingErrors(t *testing.T) {
+	matcher, err := New(nil, true)
+	assert.NoError(t, err)
+
+	err = matcher.AddMapping("\x01", "?", false)
+	var mErr *MappingError
+	assert.True(t, errors.As(err, &mErr))
+	assert.Equal(t, InvalidKey, mErr.Response)
+	assert.Equal(t, "\x01", mErr.Key)
+	assert.True(t, errors.Is(err, ErrInvalidKey))
+
+	assert.True(t, errors.Is(matcher.AddMapping("", "?", false), ErrEmptyKey))
+	assert.True(t, errors.Is(matcher.AddMapping("?", "", false), ErrEmptyValue))
+	assert.True(t, errors.Is(matcher.AddMapping("?", "\x01", false), ErrInvalidValue))
+	assert.Nil(t, Success.Err())
+
+	matcher.Close()
+}
+
+func TestClosed(t *testing.T) {
+	matcher, err := New(nil, true)
+	assert.NoError(t, err)
+
+	s, err := matcher.Snapshot()
+	assert.NoError(t, err)
+
+	assert.NoError(t, matcher.Close())
+	assert.NoError(t, matcher.Close())
+
+	index, length, err := matcher.IndexOf("A", "A", false, 0)
+	assert.Equal(t, -1, index)
+	assert.Equal(t, -1, length)
+	assert.Equal(t, ErrClosed, err)
+	assert.Equal(t, ErrClosed, matcher.AddMapping("A", "4", false))
+	assert.Equal(t, ErrClosed, matcher.RemoveMapping("A", "A"))
+	assert.Equal(t, ErrClosed, matcher.SetIgnoreList(nil))
+	_, err = matcher.Snapshot()
+	assert.Equal(t, ErrClosed, err)
+
+	index, length, err = s.IndexOf("A", "A", false, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, index)
+	assert.Equal(t, 1, length)
+
+	s.Release()
+	_, _, err = s.IndexOf("A", "A", false, 0)
+	assert.Equal(t, ErrClosed, err)
+
+	var handle CMHandle
+	index, _ = IndexOf(handle, "A", "A", false, 0)
+	assert.Equal(t, -1, index)
+	FreeConfusableMatcher(handle)
+}
+
+func TestFindAll(t *testing.T) {
+	var inMap []KeyValue
+
+	inMap = append(inMap, KeyValue{"A", "4"})
+	inMap = append(inMap, KeyValue{"A", "@"})
+
+	matcher, err := New(inMap, true)
+	assert.NoError(t, err)
+
+	matches, err := matcher.FindAll("AAA 4A@ xA", "AA", Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, []Match{{Index: 0, Length: 2}, {Index: 4, Length: 2}}, byteOffsets(matches))
+
+	matches, err = matcher.FindAll("AAA 4A@ xA", "AA", Options{Overlapping: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []Match{{Index: 0, Length: 2}, {Index: 1, Length: 2}, {Index: 4, Length: 2}, {Index: 5, Length: 2}}, byteOffsets(matches))
+
+	matches, err = matcher.FindAll("AAA 4A@ xA", "AA", Options{Overlapping: true, Max: 3})
+	assert.NoError(t, err)
+	assert.Equal(t, []Match{{Index: 0, Length: 2}, {Index: 1, Length: 2}, {Index: 4, Length: 2}}, byteOffsets(matches))
+
+	matches, err = matcher.FindAll("AAA 4A@ xA", "AA", Options{StartIndex: 3})
+	assert.NoError(t, err)
+	assert.Equal(t, []Match{{Index: 4, Length: 2}}, byteOffsets(matches))
+
+	matches, err = matcher.FindAll("AAA", "B", Options{})
+	assert.NoError(t, err)
+	assert.Nil(t, matches)
+
+	matches, err = matcher.FindAll(":)", "", Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, []Match{{Index: 0, Length: 0}}, byteOffsets(matches))
+
+	matcher.Close()
+}
+
+func TestStartIndexRange(t *testing.T) {
+	matcher, err := New(nil, true)
+	assert.NoError(t, err)
+	defer matcher.Close()
+
+	// Start indexes which do not fit 32 bits are not truncated
+	for _, el := range []struct {
+		contains   string
+		startIndex int
+		expected   []int
+	}{
+		{"BAD", math.MaxInt, []int{-1, -1}},
+		{"BAD", 4, []int{-1, -1}},
+		{"", math.MaxInt, []int{0, 0}},
+		{"BAD", math.MinInt + 1, []int{0, 3}},
+		{"AD", math.MinInt + 1, []int{1, 2}},
+	} {
+		index, length, err := matcher.IndexOf("bad", el.contains, false, el.startIndex)
+		assert.NoError(t, err)
+		assert.Equal(t, el.expected, []int{index, length}, "%q from %d", el.contains, el.startIndex)
+
+		match, err := matcher.Find(context.Background(), "bad", el.contains, Options{StartIndex: el.startIndex, Direction: Backward})
+		assert.NoError(t, err)
+		assert.Equal(t, el.expected, []int{match.Index, match.Length}, "%q from %d backward", el.contains, el.startIndex)
+	}
+
+	matches, err := matcher.FindAll("bad", "BAD", Options{StartIndex: math.MaxInt})
+	assert.NoError(t, err)
+	assert.Nil(t, matches)
+	matches, err = matcher.FindAll("bad", "", Options{StartIndex: math.MaxInt})
+	assert.NoError(t, err)
+	assert.Nil(t, matches)
+	matches, err = matcher.FindAll("bad bad", "BAD", Options{StartIndex: math.MinInt + 1})
+	assert.NoError(t, err)
+	assert.Equal(t, []Match{{Index: 0, Length: 3}, {Index: 4, Length: 3}}, byteOffsets(matches))
+}
+
+func TestFindAny(t *testing.T) {
+	var inMap []KeyValue
+
+	inMap = append(inMap, KeyValue{"A", "4"})
+	inMap = append(inMap, KeyValue{"S", "$"})
+
+	matcher, err := New(inMap, true)
+	assert.NoError(t, err)
+	assert.NoError(t, matcher.SetIgnoreList([]string{"_"}))
+
+	var needles = CompileNeedles([]string{"BAD", "ASS", "NOPE", "A"})
+	assert.Equal(t, []string{"BAD", "ASS", "NOPE", "A"}, needles.Needles())
+
+	matches, err := matcher.FindAny("b4d and 4_$s", needles, Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, []Match{
+		{Index: 0, Length: 3, Needle: 0},
+		{Index: 1, Length: 1, Needle: 3},
+		{Index: 4, Length: 1, Needle: 3},
+		{Index: 8, Length: 4, Needle: 1},
+		{Index: 8, Length: 1, Needle: 3},
+	}, byteOffsets(matches))
+
+	matches, err = matcher.FindAny("b4d and 4_$s", needles, Options{Max: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, []Match{{Index: 0, Length: 3, Needle: 0}}, byteOffsets(matches))
+
+	// Changing returned needles does not change what is searched for
+	needles.Needles()[0] = "OK"
+	assert.Equal(t, "BAD", needles.Needles()[0])
+	matches, err = matcher.FindAny("b4d", needles, Options{Max: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, []Match{{Index: 0, Length: 3, Needle: 0}}, byteOffsets(matches))
+
+	matches, err = matcher.FindAny("nothing here", CompileNeedles([]string{"BAD"}), Options{})
+	assert.NoError(t, err)
+	assert.Nil(t, matches)
+
+	assert.NoError(t, needles.Close())
+	assert.NoError(t, needles.Close())
+	_, err = matcher.FindAny("b4d", needles, Options{})
+	assert.Equal(t, ErrClosed, err)
+
+	matcher.Close()
+}
+
+func TestReplace(t *testing.T) {
+	var inMap []KeyValue
+
+	inMap = append(inMap, KeyValue{"A", "4"})
+	inMap = append(inMap, KeyValue{"A", "ä"})
+
+	matcher, err := New(inMap, true)
+	assert.NoError(t, err)
+
+	var needles = CompileNeedles([]string{"BAD", "ADD", "MEAN"})
+
+	out, err := matcher.Replace("b4dd bad meanädd ok", needles, Options{}, func(match Match) string {
+		return "<" + needles.Needles()[match.Needle] + ">"
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "<BAD> <BAD> <MEAN><ADD> ok", out)
+
+	out, err = matcher.Censor("b4dd bad meanädd ok", needles, Options{}, '*')
+	assert.NoError(t, err)
+	assert.Equal(t, "**** *** ******* ok", out)
+
+	out, err = matcher.Censor("nothing", needles, Options{}, '*')
+	assert.NoError(t, err)
+	assert.Equal(t, "nothing", out)
+
+	// Merged match spans all of the merged ones in every unit
+	var overlapping = CompileNeedles([]string{"BA", "AD"})
+	defer overlapping.Close()
+	var merged []Match
+	out, err = matcher.Replace("xbäd𝒸", overlapping, Options{}, func(match Match) string {
+		merged = append(merged, match)
+		return "*"
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "x*𝒸", out)
+	assert.Equal(t, []Match{{Index: 1, Length: 4, RuneIndex: 1, RuneLength: 3, UTF16Index: 1, UTF16Length: 3}}, merged)
+
+	needles.Close()
+	matcher.Close()
+}
+
+// byteOffsets Strips offsets in units other than bytes so matches can be compared with short literals
+func byteOffsets(Matches []Match) []Match {
+	var ret []Match
+	for _, match := range Matches {
+		ret = append(ret, Match{Index: match.Index, Length: match.Length, Needle: match.Needle})
+	}
+	return ret
+}
+
+func TestUnits(t *testing.T) {
+	var inMap []KeyValue
+
+	inMap = append(inMap, KeyValue{"A", "\x02\x03"})
+	inMap = append(inMap, KeyValue{"B", "\xC3\xBA\xC3\xBF"})
+	inMap = append(inMap, KeyValue{"C", "𝒸"})
+
+	matcher, err := New(inMap, true)
+	assert.NoError(t, err)
+
+	var in = "𝒸 \x02\x03\xC3\xBA\xC3\xBF 𝒸AB"
+	matches, err := matcher.FindAll(in, "AB", Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, []Match{
+		{Index: 5, Length: 6, RuneIndex: 2, RuneLength: 4, UTF16Index: 3, UTF16Length: 4},
+		{Index: 16, Length: 2, RuneIndex: 8, RuneLength: 2, UTF16Index: 10, UTF16Length: 2},
+	}, matches)
+
+	index, length := matches[1].Offsets(UTF16)
+	assert.Equal(t, 10, index)
+	assert.Equal(t, 2, length)
+
+	matches, err = matcher.FindAll(in, "C", Options{StartIndex: 1, Unit: UTF16})
+	assert.NoError(t, err)
+	assert.Equal(t, 12, matches[0].Index)
+	assert.Equal(t, 8, matches[0].UTF16Index)
+
+	matches, err = matcher.FindAll(in, "C", Options{StartIndex: 7, Unit: Runes})
+	assert.NoError(t, err)
+	assert.Equal(t, 12, matches[0].Index)
+	assert.Equal(t, 7, matches[0].RuneIndex)
+
+	assert.Equal(t, 4, ByteOffset(in, 1, Runes))
+	assert.Equal(t, 4, ByteOffset(in, 2, UTF16))
+	assert.Equal(t, len(in)+2, ByteOffset(in, 14, UTF16))
+
+	matcher.Close()
+}
+
+func TestExplain(t *testing.T) {
+	var inMap []KeyValue
+
+	inMap = append(inMap, KeyValue{"S", "$"})
+	inMap = append(inMap, KeyValue{"D", "[)"})
+
+	matcher, err := New(inMap, true)
+	assert.NoError(t, err)
+	assert.NoError(t, matcher.SetIgnoreList([]string{"_", " "}))
+
+	explanation, err := matcher.Explain("A__ _ $$$[)D", "ASD", Options{MatchRepeating: true})
+	assert.NoError(t, err)
+	assert.Equal(t, &Explanation{0, 11, []Segment{
+		{Mapped, "A", "A", 0, 1},
+		{Ignored, "", "_", 1, 1},
+		{Ignored, "", "_", 2, 1},
+		{Ignored, "", " ", 3, 1},
+		{Ignored, "", "_", 4, 1},
+		{Ignored, "", " ", 5, 1},
+		{Mapped, "S", "$", 6, 1},
+		{Repeated, "S", "$", 7, 1},
+		{Repeated, "S", "$", 8, 1},
+		{Mapped, "D", "[)", 9, 2},
+	}}, explanation)
+
+	explanation, err = matcher.Explain("A__ _ $$$[)D", "ASD", Options{})
+	assert.NoError(t, err)
+	assert.Nil(t, explanation)
+
+	assert.NoError(t, matcher.AddMapping("VERY", "NOT", false))
+	assert.NoError(t, matcher.AddMapping(" ", " ", false))
+	explanation, err = matcher.Explain("IT IS NOT NICE", "VERY NICE", Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, 6, explanation.Index)
+	assert.Equal(t, 8, explanation.Length)
+	assert.Equal(t, Segment{Mapped, "VERY", "NOT", 6, 3}, explanation.Segments[0])
+
+	matcher.Close()
+
+	matcher, err = New(getDefaultMap(), true)
+	assert.NoError(t, err)
+	assert.NoError(t, matcher.SetIgnoreList([]string{"_", "%", "$"}))
+
+	var inp = "AAAAAAAAASSAFSAFNFNFNISFNSIFSIFJSDFUDSHF ASUF/|/__/|/___%/|/%I%%/|//|/%%%%%NNNN/|/NN__/|/N__𝘪G___%____$__G__𝓰𝘦Ѓ"
+	explanation, err = matcher.Explain(inp, "NIGGER", Options{MatchRepeating: true})
+	assert.NoError(t, err)
+
+	var end = explanation.Index
+	for _, seg := range explanation.Segments {
+		assert.Equal(t, end, seg.Index)
+		assert.Equal(t, seg.Value, inp[seg.Index:seg.Index+seg.Length])
+		end += seg.Length
+	}
+	assert.Equal(t, explanation.Index+explanation.Length, end)
+
+	matcher.Close()
+}
+
+func TestIndexOfContext(t *testing.T) {
+	var inMap []KeyValue
+
+	inMap = append(inMap, KeyValue{"A", "a"})
+	inMap = append(inMap, KeyValue{"A", "aa"})
+
+	matcher, err := New(inMap, false)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err = matcher.IndexOfContext(ctx, "aa", "A", false, 0)
+	assert.Equal(t, context.Canceled, err)
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Minute)
+	index, length, err := matcher.IndexOfContext(ctx, "bbaa", "A", false, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, index)
+	assert.Equal(t, 1, length)
+	cancel()
+
+	var in = strings.Repeat("a", 1<<20)
+	var needle = "AB"
+
+	// Step limit makes every backend search in Go, which stops as soon as the budget is exceeded
+	assert.NoError(t, matcher.SetBudget(Budget{Steps: 1000}))
+	index, length, err = matcher.IndexOf("bbaa", "A", false, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{2, 1}, []int{index, length})
+	index, length, err = matcher.IndexOf(in, needle, false, 0)
+	assert.Equal(t, ErrBudgetExceeded, err)
+	assert.Equal(t, []int{-1, -1}, []int{index, length})
+
+	// Searches in Go return only once they stopped, so nothing is left running
+	assert.NoError(t, matcher.SetBudget(Budget{Steps: 1 << 30}))
+	_, _, err = matcher.IndexOfContext(&cancelAfter{Context: context.Background(), checks: 1}, in, needle, false, 0)
+	assert.Equal(t, context.Canceled, err)
+
+	assert.NoError(t, matcher.SetBudget(Budget{Time: time.Nanosecond, Steps: 1 << 30}))
+	index, length, err = matcher.IndexOf(in, needle, false, 0)
+	assert.Equal(t, ErrBudgetExceeded, err)
+	assert.Equal(t, []int{-1, -1}, []int{index, length})
+
+	match, err := matcher.Find(context.Background(), in, needle, Options{MaxLength: 2})
+	assert.Equal(t, ErrBudgetExceeded, err)
+	assert.Equal(t, -1, match.Index)
+
+	matcher.Close()
+}
+
+// cancelAfter Context which is canceled once its error was checked more than `checks` times,
+// stopping searches in Go at a known point without timers
+type cancelAfter struct {
+	context.Context
+	checks int
+}
+
+func (c *cancelAfter) Err() error {
+	if c.checks <= 0 {
+		return context.Canceled
+	}
+	c.checks--
+	return nil
+}
+
+// fuzzList Splits fuzzer input into at most `Max` lines, keeping inputs small enough for exhaustive matching
+func fuzzList(In string, Max int) []string {
+	if len(In) == 0 {
+		return nil
+	}
+
+	var ret = strings.Split(In, "\n")
+	if len(ret) > Max {
+		ret = ret[:Max]
+	}
+	return ret
+}
+
+func fuzzMappings(In string) []KeyValue {
+	var ret []KeyValue
+	for _, el := range fuzzList(In, 16) {
+		if kv := strings.SplitN(el, "=", 2); len(kv) == 2 {
+			ret = append(ret, KeyValue{kv[0], kv[1]})
+		}
+	}
+	return ret
+}
+
+func fuzzHasMapping(m *Matcher, Key string, Value string) bool {
+	return containsString(m.MappingsForKey(Key), Value)
+}
+
+// fuzzIndexOfSeeds Adds seeds shared by fuzz targets searching with `fuzzMatcher`
+func fuzzIndexOfSeeds(f *testing.F) {
+	f.Add("N=T\nI=E\nC=S\nE=T", "", "TEST", "NICE", true, 0)
+	f.Add("", "", ":)", "", true, 0)
+	f.Add("", "", "", ":)", true, 0)
+	f.Add("A=a\nA=aa\nB=b", "_\n\x00", "xa_aa_b", "AB", true, 1)
+	f.Add("A\x00=A\x01\nA\x01=A\x00", "\x01", "A\x01A\x00", "A\x00", false, -2)
+}
+
+// fuzzMatcher Returns matcher with default values, mappings and ignore list from fuzzer input, `nil` if it could not be created.
+// Inputs too long for exhaustive checks are skipped.
+func fuzzMatcher(t *testing.T, Mappings string, Ignore string, In string, Contains string) *Matcher {
+	if len(In) > 16 || len(Contains) > 8 {
+		t.Skip()
+	}
+
+	m, err := New(fuzzMappings(Mappings), true)
+	if !assert.NoError(t, err) {
+		return nil
+	}
+	assert.NoError(t, m.SetIgnoreList(fuzzList(Ignore, 4)))
+	return m
+}
+
+// oracleLengths Returns every length a match starting at `Start` can have, found by following all mappings,
+// repetitions and ignore list entries breadth first, independently of how the matcher searches
+func oracleLengths(Mappings []KeyValue, IgnoreList []string, In string, Contains string, MatchRepeating bool, Start int) map[int]bool {
+	type state struct {
+		in   int
+		ci   int
+		last string
+	}
+
+	var ret = make(map[int]bool)
+	var seen = make(map[state]bool)
+	var queue = []state{{Start, 0, ""}}
+	for len(queue) != 0 {
+		var s = queue[0]
+		queue = queue[1:]
+		if seen[s] {
+			continue
+		}
+		seen[s] = true
+
+		if s.ci == len(Contains) {
+			ret[s.in-Start] = true
+			continue
+		}
+
+		var rest = In[s.in:]
+		for _, kv := range Mappings {
+			if !strings.HasPrefix(rest, kv.Value) {
+				continue
+			}
+			if strings.HasPrefix(Contains[s.ci:], kv.Key) {
+				queue = append(queue, state{s.in + len(kv.Value), s.ci + len(kv.Key), kv.Key})
+			}
+			if MatchRepeating && s.in != Start && kv.Key == s.last {
+				queue = append(queue, state{s.in + len(kv.Value), s.ci, s.last})
+			}
+		}
+		if s.in != Start {
+			for _, el := range IgnoreList {
+				if len(el) != 0 && strings.HasPrefix(rest, el) {
+					queue = append(queue, state{s.in + len(el), s.ci, s.last})
+				}
+			}
+		}
+	}
+	return ret
+}
+
+func FuzzIndexOf(f *testing.F) {
+	fuzzIndexOfSeeds(f)
+
+	f.Fuzz(func(t *testing.T, Mappings string, Ignore string, In string, Contains string, MatchRepeating bool, StartIndex int) {
+		var m = fuzzMatcher(t, Mappings, Ignore, In, Contains)
+		if m == nil {
+			return
+		}
+		defer m.Close()
+
+		index, length, err := m.IndexOf(In, Contains, MatchRepeating, StartIndex)
+		assert.NoError(t, err)
+
+		var backward = Options{MatchRepeating: MatchRepeating, StartIndex: StartIndex, Direction: Backward}
+		last, err := m.Find(context.Background(), In, Contains, backward)
+		assert.NoError(t, err)
+		assert.Equal(t, index < 0, last.Index < 0)
+
+		// First and last match start where exhaustive search finds any match, with one of the lengths it finds
+		if len(Contains) != 0 {
+			var mappings, ignoreList = m.Mappings(), m.IgnoreList()
+			var first, final = -1, -1
+			var start = StartIndex
+			if start < 0 {
+				start = 0
+			}
+			for x := start; x < len(In); x++ {
+				if len(oracleLengths(mappings, ignoreList, In, Contains, MatchRepeating, x)) != 0 {
+					if first < 0 {
+						first = x
+					}
+					final = x
+				}
+			}
+
+			assert.Equal(t, first, index)
+			assert.Equal(t, final, last.Index)
+			if index >= 0 {
+				assert.True(t, oracleLengths(mappings, ignoreList, In, Contains, MatchRepeating, index)[length])
+				assert.True(t, oracleLengths(mappings, ignoreList, In, Contains, MatchRepeating, last.Index)[last.Length])
+			}
+		}
+
+		if len(Contains) == 0 {
+			assert.Equal(t, 0, index)
+			assert.Equal(t, 0, length)
+			return
+		}
+		if index < 0 {
+			assert.Equal(t, -1, index)
+			assert.Equal(t, -1, length)
+			return
+		}
+
+		assert.GreaterOrEqual(t, index, StartIndex)
+		assert.Greater(t, length, 0)
+		assert.LessOrEqual(t, index+length, len(In))
+
+		explanation, err := m.Explain(In, Contains, Options{MatchRepeating: MatchRepeating, StartIndex: StartIndex})
+		if assert.NoError(t, err) && assert.NotNil(t, explanation) {
+			assert.Equal(t, index, explanation.Index)
+			assert.Equal(t, length, explanation.Length)
+		}
+	})
+}
+
+func FuzzAddRemoveMapping(f *testing.F) {
+	f.Add("N=T\nI=E", "I", "E", "TEST", "NICE", true)
+	f.Add("", "", "?", "?", "?", false)
+	f.Add("", "\x01", "?", "?", "?", true)
+	f.Add("", "A\x00", "A\x01", "A\x01", "A", true)
+	f.Add("A=@", "A", "@", "B@B", "BAB", false)
+
+	f.Fuzz(func(t *testing.T, Mappings string, Key string, Value string, In string, Contains string, CheckValueDuplicate bool) {
+		if len(In) > 16 || len(Contains) > 8 {
+			t.Skip()
+		}
+
+		m, err := New(fuzzMappings(Mappings), true)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer m.Close()
+
+		var expected = validateMapping(Key, Value)
+		var existed = fuzzHasMapping(m, Key, Value)
+		if expected == Success && CheckValueDuplicate && existed {
+			expected = AlreadyExists
+		}
+
+		index, length, err := m.IndexOf(In, Contains, true, 0)
+		assert.NoError(t, err)
+
+		err = m.AddMapping(Key, Value, CheckValueDuplicate)
+		assert.ErrorIs(t, err, expected.Err())
+		if expected != Success {
+			assert.Equal(t, existed, m.RemoveMapping(Key, Value) == nil)
+			return
+		}
+		assert.True(t, fuzzHasMapping(m, Key, Value))
+
+		if assert.NoError(t, m.RemoveMapping(Key, Value)) && !existed {
+			assert.False(t, fuzzHasMapping(m, Key, Value))
+			assert.ErrorIs(t, m.RemoveMapping(Key, Value), ErrNotFound)
+
+			// Adding and removing a new mapping must leave matching exactly as it was
+			afterIndex, afterLength, err := m.IndexOf(In, Contains, true, 0)
+			assert.NoError(t, err)
+			assert.Equal(t, index, afterIndex)
+			assert.Equal(t, length, afterLength)
+		}
+	})
+}
+
+const confusablesSample = "\uFEFF# confusables.txt\n" +
+	"# Version: 15.1.0\n" +
+	"\n" +
+	"05AD ;\t0596 ;\tMA\t# ( ֭ → ֖ ) HEBREW ACCENT DALET → HEBREW ACCENT TIPEHA\t# \n" +
+	"0430 ;\t0061 ;\tMA\t# ( а → a ) CYRILLIC SMALL LETTER A → LATIN SMALL LETTER A\t# \n" +
+	"0391 ;\t0041 ;\tMA\t# ( Α → A ) GREEK CAPITAL LETTER ALPHA → LATIN CAPITAL LETTER A\t# \n" +
+	"0410 ;\t0041 ;\tMA\t# ( А → A ) CYRILLIC CAPITAL LETTER A → LATIN CAPITAL LETTER A\t# \n" +
+	"0410 ;\t0041 ;\tMA\t# duplicate\n" +
+	"2474 ;\t0028 0031 0029 ;\tMA\t# ( ⑴ → (l) ) PARENTHESIZED DIGIT ONE → LEFT PARENTHESIS, DIGIT ONE, RIGHT PARENTHESIS\t# \n" +
+	"0041 ;\t0041 ;\tMA\t# self\n"
+
+func TestLoadConfusables(t *testing.T) {
+	kv, err := LoadConfusables(strings.NewReader(confusablesSample), ConfusablesOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []KeyValue{
+		{"֖", "֭"},
+		{"A", "а"},
+		{"A", "Α"},
+		{"A", "А"},
+		{"(1)", "⑴"},
+	}, kv)
+
+	kv, err = LoadConfusables(strings.NewReader(confusablesSample), ConfusablesOptions{
+		Scripts: []*unicode.RangeTable{unicode.Cyrillic},
+		Targets: []*unicode.RangeTable{PrintableASCII},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []KeyValue{{"A", "а"}, {"A", "А"}}, kv)
+
+	// Lowercase homoglyphs are found with upper case needles, like default values
+	matcher, err := New(kv, true)
+	assert.NoError(t, err)
+	for _, in := range []string{"bаd", "bАd", "bad", "BAD"} {
+		index, length, err := matcher.IndexOf(in, "BAD", false, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, []int{0, len(in)}, []int{index, length}, "%q", in)
+	}
+	assert.NoError(t, matcher.Close())
+
+	_, err = LoadConfusables(strings.NewReader("0041 ;\t0041 ;\tMA\n0430 ;\tZZ ;\tMA\n"), ConfusablesOptions{})
+	assert.ErrorIs(t, err, ErrInvalidConfusables)
+	assert.Contains(t, err.Error(), "line 2")
+}
+
+func TestMappingSet(t *testing.T) {
+	var set = MappingSet{{"A", "4"}, {"A", "@"}, {"B", "|3"}, {"N", "\"\\,"}, {"X", "\xff\x00"}}
+
+	data, err := json.Marshal(set[:4])
+	assert.NoError(t, err)
+	assert.Equal(t, `[["A","4"],["A","@"],["B","|3"],["N","\"\\,"]]`, string(data))
+	var fromJSON MappingSet
+	assert.NoError(t, json.Unmarshal(data, &fromJSON))
+	assert.Equal(t, set[:4], fromJSON)
+	assert.ErrorIs(t, json.Unmarshal([]byte(`[["A"]]`), &fromJSON), ErrInvalidMappingSet)
+
+	var buf bytes.Buffer
+	assert.NoError(t, set[:4].WriteCSV(&buf))
+	fromCSV, err := ReadMappingSetCSV(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, set[:4], fromCSV)
+	_, err = ReadMappingSetCSV(strings.NewReader("A,4\nB\n"))
+	assert.Error(t, err)
+
+	data, err = set.MarshalBinary()
+	assert.NoError(t, err)
+	var fromBinary MappingSet
+	assert.NoError(t, fromBinary.UnmarshalBinary(data))
+	assert.Equal(t, set, fromBinary)
+
+	for x := range data {
+		var corrupted = append([]byte(nil), data...)
+		corrupted[x] ^= 0x20
+		assert.ErrorIs(t, fromBinary.UnmarshalBinary(corrupted), ErrInvalidMappingSet)
+	}
+	assert.ErrorIs(t, fromBinary.UnmarshalBinary(data[:len(data)-1]), ErrInvalidMappingSet)
+	assert.Equal(t, set, fromBinary)
+
+	matcher, err := New(fromBinary, false)
+	assert.NoError(t, err)
+	index, length, err := matcher.IndexOf("x|34", "BA", false, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, index)
+	assert.Equal(t, 3, length)
+	assert.NoError(t, matcher.Close())
+}
+
+func TestMappings(t *testing.T) {
+	matcher, err := New([]KeyValue{{"B", "|3"}, {"A", "4"}}, true)
+	assert.NoError(t, err)
+	assert.Equal(t, 26*2+10+2, matcher.Len())
+	assert.Equal(t, []string{"A", "a", "4"}, matcher.MappingsForKey("A"))
+	assert.Nil(t, matcher.MappingsForKey("AB"))
+
+	assert.NoError(t, matcher.AddMapping("AB", "X", false))
+	assert.NoError(t, matcher.AddMapping("A", "@", false))
+	assert.NoError(t, matcher.RemoveMapping("A", "a"))
+	assert.Equal(t, []string{"A", "4", "@"}, matcher.MappingsForKey("A"))
+	assert.Equal(t, []string{"X"}, matcher.MappingsForKey("AB"))
+	assert.Equal(t, 26*2+10+3, matcher.Len())
+
+	var mappings = matcher.Mappings()
+	assert.Len(t, mappings, matcher.Len())
+	assert.Equal(t, KeyValue{"0", "0"}, mappings[0])
+	assert.Equal(t, []KeyValue{{"A", "A"}, {"A", "4"}, {"AB", "X"}, {"A", "@"}, {"B", "B"}, {"B", "b"}, {"B", "|3"}}, mappings[10:17])
+
+	copied, err := New(mappings, false)
+	assert.NoError(t, err)
+	assert.Equal(t, mappings, copied.Mappings())
+	for _, in := range []string{"a|3", "XB", "@b", "4|3", "ab"} {
+		index, length, err := matcher.IndexOf(in, "AB", false, 0)
+		assert.NoError(t, err)
+		copiedIndex, copiedLength, err := copied.IndexOf(in, "AB", false, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, index, copiedIndex)
+		assert.Equal(t, length, copiedLength)
+	}
+
+	assert.NoError(t, copied.Close())
+	assert.NoError(t, matcher.Close())
+	assert.Nil(t, matcher.Mappings())
+	assert.Equal(t, 0, matcher.Len())
+}
+
+func TestBulkMappings(t *testing.T) {
+	matcher, err := New([]KeyValue{{"A", "4"}}, false)
+	assert.NoError(t, err)
+
+	var batch = []KeyValue{{"A", "@"}, {"A", "4"}, {"", "x"}, {"B", "8"}, {"A", "@"}}
+	res, err := matcher.AddMappings(batch, true, true)
+	assert.Equal(t, []MappingResponse{Success, AlreadyExists, EmptyKey, Success, AlreadyExists}, res)
+	var mErr *MappingError
+	assert.True(t, errors.As(err, &mErr))
+	assert.Equal(t, KeyValue{"A", "4"}, KeyValue{mErr.Key, mErr.Value})
+	assert.Equal(t, 1, matcher.Len())
+
+	res, err = matcher.AddMappings(batch, true, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []MappingResponse{Success, AlreadyExists, EmptyKey, Success, AlreadyExists}, res)
+	assert.Equal(t, []KeyValue{{"A", "4"}, {"A", "@"}, {"B", "8"}}, matcher.Mappings())
+
+	res, err = matcher.AddMappings([]KeyValue{{"C", "("}, {"C", "("}}, false, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []MappingResponse{Success, Success}, res)
+	assert.Equal(t, []string{"(", "("}, matcher.MappingsForKey("C"))
+
+	removed, err := matcher.RemoveMappings([]KeyValue{{"C", "("}, {"C", "("}, {"C", "("}}, true)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, []bool{true, true, false}, removed)
+	assert.Equal(t, 5, matcher.Len())
+
+	removed, err = matcher.RemoveMappings([]KeyValue{{"C", "("}, {"B", "8"}, {"B", "8"}}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []bool{true, true, false}, removed)
+	assert.Equal(t, []KeyValue{{"A", "4"}, {"A", "@"}, {"C", "("}}, matcher.Mappings())
+
+	index, length, err := matcher.IndexOf("x@(", "AC", false, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, index)
+	assert.Equal(t, 2, length)
+
+	res, err = matcher.AddMappings(nil, false, true)
+	assert.NoError(t, err)
+	assert.Nil(t, res)
+
+	assert.NoError(t, matcher.Close())
+	_, err = matcher.AddMappings(batch, false, false)
+	assert.ErrorIs(t, err, ErrClosed)
+	_, err = matcher.RemoveMappings(batch, false)
+	assert.ErrorIs(t, err, ErrClosed)
+}
+
+func TestTx(t *testing.T) {
+	matcher, err := New([]KeyValue{{"A", "4"}, {"B", "8"}}, false)
+	assert.NoError(t, err)
+
+	var tx = matcher.Begin()
+	tx.AddMapping("A", "@", true)
+	tx.RemoveMapping("B", "8")
+	tx.SetIgnoreList([]string{"_"})
+	tx.AddMapping("A", "4", true)
+	var mErr *MappingError
+	assert.True(t, errors.As(tx.Commit(), &mErr))
+	assert.Equal(t, AlreadyExists, mErr.Response)
+	assert.ErrorIs(t, tx.Commit(), ErrTxDone)
+	assert.Equal(t, []KeyValue{{"A", "4"}, {"B", "8"}}, matcher.Mappings())
+
+	tx = matcher.Begin()
+	tx.RemoveMapping("B", "8")
+	tx.RemoveMapping("B", "8")
+	assert.ErrorIs(t, tx.Commit(), ErrNotFound)
+	assert.Equal(t, 2, matcher.Len())
+
+	tx = matcher.Begin()
+	tx.AddMapping("A", "@", true)
+	tx.Rollback()
+	assert.ErrorIs(t, tx.Commit(), ErrTxDone)
+	assert.Equal(t, 2, matcher.Len())
+
+	tx = matcher.Begin()
+	tx.AddMapping("A", "@", true)
+	tx.AddMapping("B", "|3", true)
+	tx.RemoveMapping("B", "8")
+	tx.RemoveMapping("A", "@")
+	tx.AddMapping("A", "@", true)
+	tx.SetIgnoreList([]string{"_"})
+	assert.NoError(t, tx.Commit())
+	assert.Equal(t, []KeyValue{{"A", "4"}, {"A", "@"}, {"B", "|3"}}, matcher.Mappings())
+
+	index, length, err := matcher.IndexOf("x@_|3", "AB", false, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, index)
+	assert.Equal(t, 4, length)
+
+	assert.NoError(t, matcher.Close())
+	tx = matcher.Begin()
+	tx.AddMapping("A", "a", false)
+	assert.ErrorIs(t, tx.Commit(), ErrClosed)
+}
+
+func TestTxAtomic(t *testing.T) {
+	matcher, err := New(nil, false)
+	assert.NoError(t, err)
+	defer matcher.Close()
+
+	var stop = make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
 	go func() {
-		for running {
-			lock.Lock()
-			{
-				FreeConfusableMatcher(matcher)
-				matcher = InitConfusableMatcher(inMap, true)
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+
+			// Both mappings are added and removed together, so "AB" is either not found or matched fully
+			index, length, err := matcher.IndexOf("ab", "AB", false, 0)
+			assert.NoError(t, err)
+			assert.True(t, index == -1 || length == 2)
+			if n := matcher.Len(); n != 0 && n != 2 {
+				t.Errorf("partial update observed: %d mappings", n)
 			}
-			lock.Unlock()
-			SetIgnoreList(&matcher, ignoreList)
 		}
 	}()
 
-	time.Sleep(time.Second * 10)
+	for x := 0; x < 200; x++ {
+		var tx = matcher.Begin()
+		if x%2 == 0 {
+			tx.AddMapping("A", "a", true)
+			tx.AddMapping("B", "b", true)
+		} else {
+			tx.RemoveMapping("A", "a")
+			tx.RemoveMapping("B", "b")
+		}
+		assert.NoError(t, tx.Commit())
+	}
+	close(stop)
+	wg.Wait()
+}
+
+func TestIgnoreList(t *testing.T) {
+	matcher, err := New(nil, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{}, matcher.IgnoreList())
+
+	assert.NoError(t, matcher.AddIgnore("_", " "))
+	assert.NoError(t, matcher.AddIgnore("-", "_"))
+	assert.Equal(t, []string{"_", " ", "-"}, matcher.IgnoreList())
+
+	index, length, err := matcher.IndexOf("b-a d", "BAD", false, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, index)
+	assert.Equal(t, 5, length)
+
+	assert.NoError(t, matcher.RemoveIgnore(" ", "?"))
+	assert.Equal(t, []string{"_", "-"}, matcher.IgnoreList())
+	index, _, err = matcher.IndexOf("b-a d", "BAD", false, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, -1, index)
+
+	var list = matcher.IgnoreList()
+	list[0] = "x"
+	assert.Equal(t, []string{"_", "-"}, matcher.IgnoreList())
+
+	assert.NoError(t, matcher.SetIgnoreList([]string{"."}))
+	assert.Equal(t, []string{"."}, matcher.IgnoreList())
+
+	assert.NoError(t, matcher.Close())
+	assert.Nil(t, matcher.IgnoreList())
+	assert.ErrorIs(t, matcher.AddIgnore("_"), ErrClosed)
+	assert.ErrorIs(t, matcher.RemoveIgnore("_"), ErrClosed)
+}
+
+func TestOptions(t *testing.T) {
+	matcher, err := New([]KeyValue{{"A", "aa"}, {"A", "ä"}, {" ", " "}}, true)
+	assert.NoError(t, err)
+	defer matcher.Close()
+	assert.NoError(t, matcher.SetIgnoreList([]string{"_"}))
+
+	var ctx = context.Background()
+	var in = "xaa_b ab äb"
+
+	match, err := matcher.Find(ctx, in, "AB", Options{})
+	assert.NoError(t, err)
+	index, length, err := matcher.IndexOf(in, "AB", false, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 4}, []int{index, length})
+	assert.Equal(t, []int{index, length}, []int{match.Index, match.Length})
+
+	// Empty override ignores nothing, `nil` keeps matcher's list
+	match, err = matcher.Find(ctx, in, "AB", Options{IgnoreList: []string{}})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{6, 2}, []int{match.Index, match.Length})
+	match, err = matcher.Find(ctx, "xa__b", "AB", Options{IgnoreList: []string{"__"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 4}, []int{match.Index, match.Length})
+	assert.Equal(t, []string{"_"}, matcher.IgnoreList())
+
+	// "aa" mapping is tried first, length limit makes the single "a" match instead
+	match, err = matcher.Find(ctx, "aab", "AB", Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0, 3}, []int{match.Index, match.Length})
+	match, err = matcher.Find(ctx, "aab", "AB", Options{MaxLength: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, []int{match.Index, match.Length})
+	match, err = matcher.Find(ctx, "äb", "AB", Options{MaxLength: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, -1, match.Index)
+	match, err = matcher.Find(ctx, "äb", "AB", Options{MaxLength: 2, Unit: Runes})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0, 3, 0, 2}, []int{match.Index, match.Length, match.RuneIndex, match.RuneLength})
+
+	match, err = matcher.Find(ctx, in, "AB", Options{Direction: Backward})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{9, 3, 9, 2}, []int{match.Index, match.Length, match.RuneIndex, match.RuneLength})
+	match, err = matcher.Find(ctx, in, "AB", Options{Direction: Backward, StartIndex: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, -1, match.Index)
+	match, err = matcher.Find(ctx, in, "", Options{Direction: Backward})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0, 0}, []int{match.Index, match.Length})
+
+	matches, err := matcher.FindAll(in, "AB", Options{Direction: Backward, Max: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []Match{{9, 3, 0, 9, 2, 9, 2}, {6, 2, 0, 6, 2, 6, 2}}, matches)
+	matches, err = matcher.FindAll(in, "AB", Options{MaxLength: 2, IgnoreList: []string{}})
+	assert.NoError(t, err)
+	assert.Equal(t, []Match{{6, 2, 0, 6, 2, 6, 2}}, matches)
+	matches, err = matcher.FindAll(in, "AB", Options{MaxLength: 2, Unit: Runes, IgnoreList: []string{}})
+	assert.NoError(t, err)
+	assert.Equal(t, []Match{{6, 2, 0, 6, 2, 6, 2}, {9, 3, 0, 9, 2, 9, 2}}, matches)
+
+	explanation, err := matcher.Explain(in, "AB", Options{Direction: Backward, Unit: Runes, StartIndex: 5})
+	assert.NoError(t, err)
+	assert.Equal(t, 9, explanation.Index)
+	assert.Equal(t, "ä", explanation.Segments[0].Value)
+	explanation, err = matcher.Explain("xa__b", "AB", Options{IgnoreList: []string{"__"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []Segment{{Mapped, "A", "a", 1, 1}, {Ignored, "", "__", 2, 2}, {Mapped, "B", "b", 4, 1}}, explanation.Segments)
+}
+
+func TestNUL(t *testing.T) {
+	matcher, err := New([]KeyValue{{"A", "4"}}, true)
+	assert.NoError(t, err)
+	defer matcher.Close()
+
+	// Text after a NUL byte is searched, matches never span it unless something maps or ignores it
+	index, length, err := matcher.IndexOf("ok\x00b4d", "BAD", false, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{3, 3}, []int{index, length})
+	index, _, err = matcher.IndexOf("b\x004d", "BAD", false, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, -1, index)
+
+	matches, err := matcher.FindAll("bad\x00\x00bad\x00x\x00bad", "BAD", Options{StartIndex: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{5, 11}, []int{matches[0].Index, matches[1].Index})
+	assert.Len(t, matches, 2)
+	match, err := matcher.Find(context.Background(), "bad\x00bad\x00x", "BAD", Options{Direction: Backward})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{4, 3}, []int{match.Index, match.Length})
+
+	// Needle is not cut at NUL byte
+	index, _, err = matcher.IndexOf("bad", "BAD\x00", false, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, -1, index)
+
+	assert.NoError(t, matcher.SetIgnoreList([]string{"\x00"}))
+	index, length, err = matcher.IndexOf("b\x00\x004d", "BAD", false, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0, 5}, []int{index, length})
+	assert.NoError(t, matcher.SetIgnoreList(nil))
+
+	// Keys and values are not cut at NUL byte either
+	assert.NoError(t, matcher.AddMapping("B\x00", "8", true))
+	assert.ErrorIs(t, matcher.AddMapping("B\x00", "8", true), ErrAlreadyExists)
+	assert.ErrorIs(t, matcher.AddMapping("A", "\x00", true), ErrInvalidValue)
+	assert.NoError(t, matcher.AddMapping("D", "|\x00)", true))
+	assert.Equal(t, []string{"8"}, matcher.MappingsForKey("B\x00"))
+	assert.Equal(t, []string{"D", "d", "|\x00)"}, matcher.MappingsForKey("D"))
+
+	index, length, err = matcher.IndexOf("x84|\x00)", "B\x00AD", false, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 5}, []int{index, length})
+	index, length, err = matcher.IndexOf("b4|\x00)", "BAD", false, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0, 5}, []int{index, length})
+	explanation, err := matcher.Explain("b4|\x00)", "BAD", Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, Segment{Mapped, "D", "|\x00)", 2, 3}, explanation.Segments[2])
+
+	res, err := matcher.RemoveMappings([]KeyValue{{"B\x00", "8"}, {"D", "|\x00)"}, {"D", "d"}}, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []bool{true, true, true}, res)
+	index, _, err = matcher.IndexOf("b4|\x00)", "BAD", false, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, -1, index)
+}
+
+func TestBytes(t *testing.T) {
+	matcher, err := New([]KeyValue{{"A", "4"}, {"A", "ä"}}, true)
+	assert.NoError(t, err)
+	defer matcher.Close()
+
+	var in = []byte("x b4d b\x00äd bäd")
+	index, length, err := matcher.IndexOfBytes(in, "BAD", false, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{2, 3}, []int{index, length})
+
+	match, err := matcher.FindBytes(context.Background(), in, "BAD", Options{Direction: Backward})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{12, 4, 11, 3}, []int{match.Index, match.Length, match.RuneIndex, match.RuneLength})
+
+	matches, err := matcher.FindAllBytes(in, "BAD", Options{})
+	assert.NoError(t, err)
+	expected, err := matcher.FindAll(string(in), "BAD", Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, expected, matches)
+	assert.Len(t, matches, 2)
+
+	var needles = CompileNeedles([]string{"BAD", "X"})
+	defer needles.Close()
+	matches, err = matcher.FindAnyBytes(in, needles, Options{})
+	assert.NoError(t, err)
+	assert.Len(t, matches, 3)
+
+	index, _, err = matcher.IndexOfBytes(nil, "BAD", false, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, -1, index)
+
+	// Input is shared with the slice unless the search may outlive the call because of the budget,
+	// in which case later changes of the slice must not be seen
+	var shared = searchString(context.Background(), matcher.currentBudget(), in)
+	assert.NoError(t, matcher.SetBudget(Budget{Time: time.Minute}))
+	var copied = searchString(context.Background(), matcher.currentBudget(), in)
+	var withoutDone = searchString(&cancelAfter{Context: context.Background()}, Budget{}, in)
+	var withDone, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	var copiedForContext = searchString(withDone, Budget{}, in)
+	in[3] = 'X'
+	assert.Equal(t, "x bXd", shared[:5])
+	assert.Equal(t, "x b4d", copied[:5])
+	assert.Equal(t, "x bXd", withoutDone[:5])
+	assert.Equal(t, "x b4d", copiedForContext[:5])
+
+	index, _, err = matcher.IndexOfBytes(in, "BAD", false, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 12, index)
+}
+
+func benchmarkMatcher(b *testing.B) (*Matcher, []byte) {
+	matcher, err := New([]KeyValue{{"A", "4"}, {"A", "@"}, {"S", "$"}}, true)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { matcher.Close() })
+
+	return matcher, []byte(strings.Repeat("this is a fairly ordinary chat message, ", 25) + "b4d")
+}
+
+func BenchmarkIndexOf(b *testing.B) {
+	matcher, in := benchmarkMatcher(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for x := 0; x < b.N; x++ {
+		matcher.IndexOf(string(in), "BAD", false, 0)
+	}
+}
+
+func BenchmarkIndexOfBytes(b *testing.B) {
+	matcher, in := benchmarkMatcher(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for x := 0; x < b.N; x++ {
+		matcher.IndexOfBytes(in, "BAD", false, 0)
+	}
+}
+
+func BenchmarkFindAllBytes(b *testing.B) {
+	matcher, in := benchmarkMatcher(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for x := 0; x < b.N; x++ {
+		matcher.FindAllBytes(in, "A", Options{})
+	}
+}
+
+func TestScanner(t *testing.T) {
+	matcher, err := New([]KeyValue{{"A", "4"}, {"A", "ää"}, {"S", "$"}}, true)
+	assert.NoError(t, err)
+	defer matcher.Close()
+	assert.NoError(t, matcher.SetIgnoreList([]string{"_", "💩"}))
+
+	var needles = CompileNeedles([]string{"BAD", "ASS", "A"})
+	defer needles.Close()
+
+	var in = strings.Repeat("ok b4d bää_d $ a$$ 💩b💩a💩d ", 20) + "b4"
+	for _, options := range []Options{
+		{MaxLength: 12},
+		{MaxLength: 5, Unit: Runes, Overlapping: true},
+		{MaxLength: 3, Unit: UTF16, MatchRepeating: true},
+	} {
+		expected, err := matcher.FindAny(in, needles, options)
+		assert.NoError(t, err)
+
+		for _, size := range []int{1, 3, 7, 64, len(in)} {
+			var found []Match
+			scanner, err := matcher.NewScanner(needles, options, func(match Match) error {
+				found = append(found, match)
+				return nil
+			})
+			assert.NoError(t, err)
+
+			for x := 0; x < len(in); x += size {
+				var end = x + size
+				if end > len(in) {
+					end = len(in)
+				}
+				n, err := scanner.Write([]byte(in[x:end]))
+				assert.NoError(t, err)
+				assert.Equal(t, end-x, n)
+			}
+			assert.NoError(t, scanner.Close())
+			assert.Equal(t, expected, found, "chunk size %d, options %+v", size, options)
+			assert.LessOrEqual(t, len(scanner.buf), options.MaxLength*4+size)
+		}
+	}
+
+	var count = 0
+	scanner, err := matcher.NewScanner(needles, Options{MaxLength: 8, Max: 3}, func(Match) error {
+		count++
+		return nil
+	})
+	assert.NoError(t, err)
+	n, err := scanner.ReadFrom(strings.NewReader(in))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(in)), n)
+	assert.NoError(t, scanner.Close())
+	assert.Equal(t, 3, count)
+	_, err = scanner.Write([]byte("bad"))
+	assert.ErrorIs(t, err, ErrClosed)
+
+	var stop = errors.New("stop")
+	scanner, err = matcher.NewScanner(needles, Options{MaxLength: 8}, func(Match) error { return stop })
+	assert.NoError(t, err)
+	_, err = scanner.Write([]byte("bad"))
+	assert.NoError(t, err)
+	assert.ErrorIs(t, scanner.Close(), stop)
+
+	_, err = matcher.NewScanner(needles, Options{}, nil)
+	assert.ErrorIs(t, err, ErrMaxLengthRequired)
+	_, err = matcher.NewScanner(needles, Options{MaxLength: 8, Direction: Backward}, nil)
+	assert.ErrorIs(t, err, ErrUnsupportedOption)
+}
+
+func TestCensorWriter(t *testing.T) {
+	matcher, err := New([]KeyValue{{"A", "4"}, {"A", "ää"}, {"S", "$"}}, true)
+	assert.NoError(t, err)
+	defer matcher.Close()
+	assert.NoError(t, matcher.SetIgnoreList([]string{"_", "💩"}))
+
+	var needles = CompileNeedles([]string{"BAD", "ASS"})
+	defer needles.Close()
+
+	var in = strings.Repeat("ok b4d bää_d $ a$$ 💩b💩a💩d ", 20) + "b4"
+	for _, options := range []Options{
+		{MaxLength: 12},
+		{MaxLength: 5, Unit: Runes, Overlapping: true},
+	} {
+		expected, err := matcher.Censor(in, needles, options, '█')
+		assert.NoError(t, err)
+
+		for _, size := range []int{1, 3, 7, 64, len(in)} {
+			var out bytes.Buffer
+			w, err := NewCensorWriter(&out, matcher, needles, options, '█')
+			assert.NoError(t, err)
+
+			for x := 0; x < len(in); x += size {
+				var end = x + size
+				if end > len(in) {
+					end = len(in)
+				}
+				n, err := w.Write([]byte(in[x:end]))
+				assert.NoError(t, err)
+				assert.Equal(t, end-x, n)
+				assert.LessOrEqual(t, len(w.pending), (options.MaxLength+1)*utf8.UTFMax+size)
+			}
+			assert.NoError(t, w.Close())
+			assert.NoError(t, w.Close())
+			assert.Equal(t, expected, out.String(), "chunk size %d, options %+v", size, options)
+
+			_, err = w.Write([]byte("bad"))
+			assert.ErrorIs(t, err, ErrClosed)
+		}
+
+		r, err := NewCensorReader(strings.NewReader(in), matcher, needles, options, '█')
+		assert.NoError(t, err)
+		out, err := io.ReadAll(r)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, string(out))
+	}
+
+	var out bytes.Buffer
+	w, err := NewCensorWriter(&out, matcher, needles, Options{MaxLength: 8, Max: 2}, '*')
+	assert.NoError(t, err)
+	for _, el := range []string{"b4d ba", "$$ b", "ad b", "4d"} {
+		_, err = w.Write([]byte(el))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, w.Close())
+	assert.Equal(t, "*** b*** bad b4d", out.String())
+
+	_, err = NewCensorWriter(io.Discard, matcher, needles, Options{}, '*')
+	assert.ErrorIs(t, err, ErrMaxLengthRequired)
+	_, err = NewCensorReader(strings.NewReader(in), matcher, needles, Options{}, '*')
+	assert.ErrorIs(t, err, ErrMaxLengthRequired)
+}
+
+func TestBoundary(t *testing.T) {
+	matcher, err := New([]KeyValue{{"S", "$"}}, true)
+	assert.NoError(t, err)
+	defer matcher.Close()
+	assert.NoError(t, matcher.SetIgnoreList([]string{"_", "💩"}))
+
+	var ctx = context.Background()
+	var word = Options{Boundary: WordBoundary}
+
+	for _, el := range []struct {
+		in       string
+		options  Options
+		expected []int
+	}{
+		{"classic", Options{}, []int{2, 3}},
+		{"classic", word, []int{-1, -1}},
+		{"cla$$ic", word, []int{-1, -1}},
+		{"you a$$!", word, []int{4, 3}},
+		{"中ass", word, []int{3, 3}},
+		{"assx ass", word, []int{5, 3}},
+		// Ignored entries are invisible to boundaries, inside and next to the match
+		{"cl_ass", word, []int{-1, -1}},
+		{"💩_ass_💩", word, []int{5, 3}},
+		{"a_s💩s", word, []int{0, 8}},
+		{"a_s_s_x", word, []int{-1, -1}},
+		{"cl-ass", word, []int{3, 3}},
+		// Ignored boundary characters still separate words
+		{"cl-ass", Options{Boundary: WordBoundary, IgnoreList: []string{"-"}}, []int{3, 3}},
+		{"you ass here", Options{Boundary: WordBoundary, IgnoreList: []string{" "}}, []int{4, 3}},
+		{"you ass here", Options{Boundary: SpaceBoundary, IgnoreList: []string{" "}}, []int{4, 3}},
+		{"you _ass_ here", Options{Boundary: SpaceBoundary, IgnoreList: []string{" ", "_"}}, []int{5, 3}},
+		{"you_ass here", Options{Boundary: SpaceBoundary, IgnoreList: []string{" ", "_"}}, []int{-1, -1}},
+		{"class", Options{Boundary: WordBoundary, IgnoreList: []string{" "}}, []int{-1, -1}},
+		{"ass.", Options{Boundary: SpaceBoundary}, []int{-1, -1}},
+		{"x ass\t", Options{Boundary: SpaceBoundary}, []int{2, 3}},
+		{"x,ass,y", Options{Boundary: BoundarySet(",")}, []int{2, 3}},
+		{"x ass", Options{Boundary: BoundarySet(",")}, []int{-1, -1}},
+		// Repeating the last key may reach a boundary the shortest match does not end at
+		{"asss", word, []int{-1, -1}},
+		{"asss", Options{Boundary: WordBoundary, MatchRepeating: true}, []int{0, 4}},
+		{"ass_s ", Options{Boundary: WordBoundary, MatchRepeating: true}, []int{0, 5}},
+		{"ass ass", Options{Boundary: WordBoundary, Direction: Backward}, []int{4, 3}},
+	} {
+		match, err := matcher.Find(ctx, el.in, "ASS", el.options)
+		assert.NoError(t, err)
+		assert.Equal(t, el.expected, []int{match.Index, match.Length}, "%q", el.in)
+	}
+
+	matches, err := matcher.FindAll("ass classic a$$ bass", "ASS", word)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(matches))
+	assert.Equal(t, []int{0, 12}, []int{matches[0].Index, matches[1].Index})
+
+	explanation, err := matcher.Explain("asss!", "ASS", Options{Boundary: WordBoundary, MatchRepeating: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 4, explanation.Length)
+	assert.Equal(t, Repeated, explanation.Segments[3].Kind)
 
-	running = false
+	var needles = CompileNeedles([]string{"ASS"})
+	defer needles.Close()
+	_, err = matcher.NewScanner(needles, Options{MaxLength: 8, Boundary: WordBoundary}, nil)
+	assert.ErrorIs(t, err, ErrUnsupportedOption)
 }
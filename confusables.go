@@ -0,0 +1,138 @@
+package confusablematcher
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// PrintableASCII range table of printable ASCII characters, to be used with `ConfusablesOptions.Targets`
+var PrintableASCII = &unicode.RangeTable{
+	R16:         []unicode.Range16{{Lo: 0x20, Hi: 0x7E, Stride: 1}},
+	LatinOffset: 1,
+}
+
+// ConfusablesOptions filters entries loaded by `LoadConfusables`
+type ConfusablesOptions struct {
+	// Scripts If not empty, keeps only entries whose source characters all belong to one of the tables,
+	// for example `unicode.Cyrillic` or `unicode.Greek`
+	Scripts []*unicode.RangeTable
+	// Targets If not empty, keeps only entries whose target characters all belong to one of the tables,
+	// for example `PrintableASCII` or `unicode.Latin`
+	Targets []*unicode.RangeTable
+}
+
+// LoadConfusables Parses Unicode UTS #39 `confusables.txt` into key to value mappings.
+// Target (prototype) of each entry becomes the key, since that is what needles are written in,
+// and the confusable source character becomes the value which is found in input strings.
+// Keys are upper case like needles and default values, so a target made of a single lowercase Latin letter
+// is upper-cased: CYRILLIC SMALL LETTER A becomes a value of key "A", which default values map to "a" and "A" too.
+// Other targets are kept as they are.
+//
+// Parameters:
+//
+// - `R` : Contents of `confusables.txt`
+// - `Options` : Entry filters, zero value keeps every entry
+//
+// Returns:
+//
+// - Key to value mappings in file order, without duplicates and entries mapping a character to itself
+// - `ErrInvalidConfusables` wrapped with line number if the file could not be parsed, or error returned by `R`
+func LoadConfusables(R io.Reader, Options ConfusablesOptions) ([]KeyValue, error) {
+	var ret []KeyValue
+	var seen = make(map[KeyValue]bool)
+
+	var scanner = bufio.NewScanner(R)
+	for line := 1; scanner.Scan(); line++ {
+		var text = scanner.Text()
+		if line == 1 {
+			text = strings.TrimPrefix(text, "\uFEFF")
+		}
+		if x := strings.IndexByte(text, '#'); x >= 0 {
+			text = text[:x]
+		}
+		if len(strings.TrimSpace(text)) == 0 {
+			continue
+		}
+
+		var fields = strings.Split(text, ";")
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("%w: line %d", ErrInvalidConfusables, line)
+		}
+		source, err := parseCodePoints(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrInvalidConfusables, line, err)
+		}
+		target, err := parseCodePoints(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrInvalidConfusables, line, err)
+		}
+
+		if !allOf(source, Options.Scripts) || !allOf(target, Options.Targets) {
+			continue
+		}
+		target = upperLatin(target)
+		if source == target {
+			continue
+		}
+
+		var kv = KeyValue{target, source}
+		if !seen[kv] {
+			seen[kv] = true
+			ret = append(ret, kv)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+// upperLatin Returns upper case form of a single lowercase Latin letter, other strings are returned as they are
+func upperLatin(In string) string {
+	r, width := utf8.DecodeRuneInString(In)
+	if width != len(In) || !unicode.Is(unicode.Latin, r) || !unicode.IsLower(r) {
+		return In
+	}
+	return string(unicode.ToUpper(r))
+}
+
+// parseCodePoints Parses space separated hexadecimal code points
+func parseCodePoints(In string) (string, error) {
+	var fields = strings.Fields(In)
+	if len(fields) == 0 {
+		return "", fmt.Errorf("no code points")
+	}
+
+	var sb strings.Builder
+	for _, el := range fields {
+		cp, err := strconv.ParseUint(el, 16, 32)
+		if err != nil {
+			return "", err
+		}
+		if cp == 0 || cp == 1 || cp > unicode.MaxRune || (cp >= 0xD800 && cp <= 0xDFFF) {
+			return "", fmt.Errorf("invalid code point %s", el)
+		}
+		sb.WriteRune(rune(cp))
+	}
+	return sb.String(), nil
+}
+
+// allOf Reports whether every rune of the string belongs to one of the tables, always true if there are no tables
+func allOf(In string, Tables []*unicode.RangeTable) bool {
+	if len(Tables) == 0 {
+		return true
+	}
+
+	for _, r := range In {
+		if !unicode.IsOneOf(Tables, r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,85 @@
+package confusablematcher
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrInitFailed native confusable matcher or ignore list could not be constructed
+	ErrInitFailed = errors.New("confusablematcher: native initialization failed")
+	// ErrOutOfMemory native code could not allocate memory for results
+	ErrOutOfMemory = errors.New("confusablematcher: native memory allocation failed")
+	// ErrClosed matcher or snapshot was already freed
+	ErrClosed = errors.New("confusablematcher: use of closed matcher")
+	// ErrBudgetExceeded search did not finish within matcher's budget
+	ErrBudgetExceeded = errors.New("confusablematcher: search budget exceeded")
+	// ErrNoExplanation match reported by native matcher could not be reconstructed from known mappings
+	ErrNoExplanation = errors.New("confusablematcher: match could not be explained")
+	// ErrNotFound key and value combination does not exist
+	ErrNotFound = errors.New("confusablematcher: key and value combination does not exist")
+	// ErrAlreadyExists key and value combination already exists
+	ErrAlreadyExists = errors.New("confusablematcher: key and value combination already exists")
+	// ErrEmptyKey key is empty
+	ErrEmptyKey = errors.New("confusablematcher: key is empty")
+	// ErrEmptyValue value is empty
+	ErrEmptyValue = errors.New("confusablematcher: value is empty")
+	// ErrInvalidKey key starts with 0x00 or 0x01
+	ErrInvalidKey = errors.New("confusablematcher: key starts with 0x00 or 0x01")
+	// ErrInvalidValue value starts with 0x00 or 0x01
+	ErrInvalidValue = errors.New("confusablematcher: value starts with 0x00 or 0x01")
+	// ErrInvalidConfusables confusables file could not be parsed
+	ErrInvalidConfusables = errors.New("confusablematcher: invalid confusables file")
+	// ErrInvalidMappingSet encoded mapping set is corrupted or of unsupported version
+	ErrInvalidMappingSet = errors.New("confusablematcher: invalid mapping set")
+	// ErrTxDone transaction was already committed or rolled back
+	ErrTxDone = errors.New("confusablematcher: transaction already committed or rolled back")
+	// ErrMaxLengthRequired streaming search needs matches limited with `Options.MaxLength`
+	ErrMaxLengthRequired = errors.New("confusablematcher: streaming search requires MaxLength")
+	// ErrUnsupportedOption option is not supported by the operation
+	ErrUnsupportedOption = errors.New("confusablematcher: option is not supported by the operation")
+)
+
+// Err Returns error matching the response, `nil` for `Success`
+func (r MappingResponse) Err() error {
+	switch r {
+	case Success:
+		return nil
+	case AlreadyExists:
+		return ErrAlreadyExists
+	case EmptyKey:
+		return ErrEmptyKey
+	case EmptyValue:
+		return ErrEmptyValue
+	case InvalidKey:
+		return ErrInvalidKey
+	case InvalidValue:
+		return ErrInvalidValue
+	}
+
+	return fmt.Errorf("confusablematcher: unknown mapping response %d", int(r))
+}
+
+// MappingError describes a key to value mapping which could not be added.
+// It unwraps to one of `ErrAlreadyExists`, `ErrEmptyKey`, `ErrEmptyValue`, `ErrInvalidKey` or `ErrInvalidValue`.
+type MappingError struct {
+	Key      string
+	Value    string
+	Response MappingResponse
+}
+
+func (e *MappingError) Error() string {
+	return fmt.Sprintf("%v (key %q, value %q)", e.Response.Err(), e.Key, e.Value)
+}
+
+func (e *MappingError) Unwrap() error {
+	return e.Response.Err()
+}
+
+func mappingError(Key string, Value string, Response MappingResponse) error {
+	if Response == Success {
+		return nil
+	}
+
+	return &MappingError{Key, Value, Response}
+}
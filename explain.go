@@ -0,0 +1,149 @@
+package confusablematcher
+
+import "strings"
+
+// SegmentKind describes how a part of input was consumed by a match
+type SegmentKind int
+
+const (
+	// Mapped Input consumed by a mapping of needle key
+	Mapped SegmentKind = 0
+	// Repeated Input consumed by repeating mapping of previous key, needle is not consumed (`MatchRepeating`)
+	Repeated SegmentKind = 1
+	// Ignored Input skipped because it is on the ignore list, needle is not consumed
+	Ignored SegmentKind = 2
+)
+
+// Segment single step of an explained match
+type Segment struct {
+	Kind SegmentKind
+	// Key Needle key which was consumed or repeated, empty for ignored segments
+	Key string
+	// Value Mapping value or ignore list entry, which is the input substring at `Index`
+	Value string
+	// Index Byte offset of the segment in input string
+	Index int
+	// Length Length of the segment in bytes
+	Length int
+}
+
+// Explanation describes how the needle matched input string
+type Explanation struct {
+	// Index Byte offset of the match in input string
+	Index int
+	// Length Length of the match in bytes
+	Length int
+	// Segments Steps of the match in input order, covering it completely
+	Segments []Segment
+}
+
+type traceState struct {
+	in   int
+	ci   int
+	last string
+}
+
+// tracer Reconstructs segments of a known match from Go side copy of mappings and ignore list
+type tracer struct {
+	mappings       *mappingTable
+	ignoreList     []string
+	in             string
+	contains       string
+	matchRepeating bool
+	start          int
+	end            int
+	maxLength      int
+	unit           Unit
+	boundary       Boundary
+	budget         *searchLimit
+	limit          int
+	found          int
+	record         bool
+	failed         map[traceState]bool
+	segments       []Segment
+}
+
+// explain Returns segments taking needle from `Index` to exactly `Index + Length`, false if there is no such path
+func explain(Mappings *mappingTable, IgnoreList []string, In string, Contains string, MatchRepeating bool, Boundary Boundary, Index int, Length int) ([]Segment, bool) {
+	var t = tracer{
+		mappings:       Mappings,
+		ignoreList:     IgnoreList,
+		in:             In[:Index+Length],
+		contains:       Contains,
+		matchRepeating: MatchRepeating,
+		start:          Index,
+		end:            Index + Length,
+		boundary:       Boundary,
+		record:         true,
+		failed:         make(map[traceState]bool),
+	}
+
+	if !t.walk(Index, 0, "") {
+		return nil, false
+	}
+	return t.segments, true
+}
+
+func (t *tracer) walk(In int, Ci int, Last string) bool {
+	if t.budget.exceeded() {
+		return false
+	}
+	if t.maxLength > 0 && In > t.limit {
+		return false
+	}
+	if Ci == len(t.contains) {
+		if (t.end < 0 || In == t.end) && (t.boundary == nil || t.endsAt(In)) {
+			t.found = In
+			return true
+		}
+		// Match which does not end at a boundary may still reach one by repeating the last key
+		if t.boundary == nil || !t.matchRepeating {
+			return false
+		}
+	}
+
+	var state = traceState{In, Ci, Last}
+	if !t.matchRepeating {
+		state.last = ""
+	}
+	if t.failed[state] {
+		return false
+	}
+
+	var rest = t.in[In:]
+	if Ci < len(t.contains) && t.mappings.eachMapping(rest, t.contains[Ci:], false, func(kv KeyValue) bool {
+		return t.step(Segment{Mapped, kv.Key, kv.Value, In, len(kv.Value)}, Ci+len(kv.Key), kv.Key)
+	}) {
+		return true
+	}
+
+	if In != t.start {
+		if t.matchRepeating && t.mappings.eachMapping(rest, Last, true, func(kv KeyValue) bool {
+			return t.step(Segment{Repeated, kv.Key, kv.Value, In, len(kv.Value)}, Ci, Last)
+		}) {
+			return true
+		}
+
+		for _, el := range t.ignoreList {
+			if len(el) != 0 && strings.HasPrefix(rest, el) && t.step(Segment{Ignored, "", el, In, len(el)}, Ci, Last) {
+				return true
+			}
+		}
+	}
+
+	t.failed[state] = true
+	return false
+}
+
+func (t *tracer) step(Seg Segment, Ci int, Last string) bool {
+	if !t.record {
+		return t.walk(Seg.Index+Seg.Length, Ci, Last)
+	}
+
+	t.segments = append(t.segments, Seg)
+	if t.walk(Seg.Index+Seg.Length, Ci, Last) {
+		return true
+	}
+	t.segments = t.segments[:len(t.segments)-1]
+	return false
+}
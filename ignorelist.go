@@ -0,0 +1,94 @@
+package confusablematcher
+
+// IgnoreList Returns strings currently ignored by the matcher, `nil` if matcher is closed
+func (m *Matcher) IgnoreList() []string {
+	if m == nil {
+		return nil
+	}
+
+	s, err := m.Snapshot()
+	if err != nil {
+		return nil
+	}
+	defer s.Release()
+
+	return append([]string{}, s.ignoreList()...)
+}
+
+// AddIgnore Adds strings to the ignore list, strings already on it are not added again
+//
+// Parameters:
+//
+// - `In` : Strings to ignore
+//
+// Returns:
+//
+// - `ErrInitFailed` if native ignore list could not be constructed, previous list is kept in that case
+func (m *Matcher) AddIgnore(In ...string) error {
+	return m.updateIgnoreList(func(Current []string) []string {
+		var ret = append([]string(nil), Current...)
+		for _, el := range In {
+			if !containsString(ret, el) {
+				ret = append(ret, el)
+			}
+		}
+		return ret
+	})
+}
+
+// RemoveIgnore Removes strings from the ignore list, strings not on it are skipped
+//
+// Parameters:
+//
+// - `In` : Strings to stop ignoring
+//
+// Returns:
+//
+// - `ErrInitFailed` if native ignore list could not be constructed, previous list is kept in that case
+func (m *Matcher) RemoveIgnore(In ...string) error {
+	return m.updateIgnoreList(func(Current []string) []string {
+		var ret []string
+		for _, el := range Current {
+			if !containsString(In, el) {
+				ret = append(ret, el)
+			}
+		}
+		return ret
+	})
+}
+
+// updateIgnoreList Publishes a snapshot with the ignore list returned by `fn` from the current list.
+// The snapshot shares native matcher with the current one, only the ignore list is built.
+func (m *Matcher) updateIgnoreList(fn func(Current []string) []string) error {
+	if m == nil {
+		return ErrClosed
+	}
+
+	m.write.Lock()
+	defer m.write.Unlock()
+
+	return m.replace(func(Current *Snapshot) (*Snapshot, error) {
+		return Current.withIgnoreList(fn(Current.ignoreList()))
+	})
+}
+
+func containsString(List []string, In string) bool {
+	for _, el := range List {
+		if el == In {
+			return true
+		}
+	}
+	return false
+}
+
+func equalStrings(A []string, B []string) bool {
+	if len(A) != len(B) {
+		return false
+	}
+	for x := range A {
+		if A[x] != B[x] {
+			return false
+		}
+	}
+	return true
+}
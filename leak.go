@@ -0,0 +1,7 @@
+//go:build !confusablematcher_debug
+
+package confusablematcher
+
+// reportLeak Called when an object holding native memory is garbage collected without being closed or released.
+// Build with `confusablematcher_debug` tag to log these.
+func reportLeak(what string) {}
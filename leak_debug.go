@@ -0,0 +1,9 @@
+//go:build confusablematcher_debug
+
+package confusablematcher
+
+import "log"
+
+func reportLeak(what string) {
+	log.Printf("confusablematcher: %s was garbage collected without being closed, freeing native memory", what)
+}
@@ -0,0 +1,201 @@
+package confusablematcher
+
+import "strings"
+
+// mappingTable Go side copy of key to value mappings, kept in insertion order like the native matcher.
+// Mappings are grouped by first byte of the key, so all keys which can match at a needle position are in one group.
+type mappingTable struct {
+	byFirst map[byte][]KeyValue
+	count   int
+	// nul Number of mappings whose key or value contains 0x00 byte
+	nul int
+}
+
+func newMappingTable(InputMap []KeyValue, AddDefaultValues bool) *mappingTable {
+	var t = &mappingTable{byFirst: make(map[byte][]KeyValue)}
+
+	if AddDefaultValues {
+		for x := 'A'; x <= 'Z'; x++ {
+			t.add(string(x), string(x), false)
+			t.add(string(x), string(x+0x20), false)
+		}
+		for x := '0'; x <= '9'; x++ {
+			t.add(string(x), string(x), false)
+		}
+	}
+
+	for _, el := range InputMap {
+		t.add(el.Key, el.Value, false)
+	}
+
+	return t
+}
+
+// hasNUL Reports whether string contains 0x00 byte, which native code cannot be given
+func hasNUL(In string) bool {
+	return strings.IndexByte(In, 0) >= 0
+}
+
+// validateMapping Checks key and value the same way native matcher does
+func validateMapping(Key string, Value string) MappingResponse {
+	if len(Key) == 0 {
+		return EmptyKey
+	}
+	if len(Value) == 0 {
+		return EmptyValue
+	}
+	if Key[0] == 0x00 || Key[0] == 0x01 {
+		return InvalidKey
+	}
+	if Value[0] == 0x00 || Value[0] == 0x01 {
+		return InvalidValue
+	}
+	return Success
+}
+
+func (t *mappingTable) add(Key string, Value string, CheckValueDuplicate bool) MappingResponse {
+	if res := validateMapping(Key, Value); res != Success {
+		return res
+	}
+
+	if CheckValueDuplicate {
+		for _, el := range t.byFirst[Key[0]] {
+			if el.Key == Key && el.Value == Value {
+				return AlreadyExists
+			}
+		}
+	}
+
+	t.byFirst[Key[0]] = append(t.byFirst[Key[0]], KeyValue{Key, Value})
+	t.count++
+	if hasNUL(Key) || hasNUL(Value) {
+		t.nul++
+	}
+	return Success
+}
+
+func (t *mappingTable) remove(Key string, Value string) bool {
+	if len(Key) == 0 {
+		return false
+	}
+
+	var group = t.byFirst[Key[0]]
+	for x, el := range group {
+		if el.Key == Key && el.Value == Value {
+			t.byFirst[Key[0]] = append(group[:x:x], group[x+1:]...)
+			t.count--
+			if hasNUL(Key) || hasNUL(Value) {
+				t.nul--
+			}
+			return true
+		}
+	}
+	return false
+}
+
+func (t *mappingTable) countOf(Key string, Value string) int {
+	var ret int
+	if len(Key) != 0 {
+		for _, el := range t.byFirst[Key[0]] {
+			if el.Key == Key && el.Value == Value {
+				ret++
+			}
+		}
+	}
+	return ret
+}
+
+// mappingPredictor Simulates adding and removing mappings one after another without changing the table
+type mappingPredictor struct {
+	table *mappingTable
+	delta map[KeyValue]int
+}
+
+func (t *mappingTable) predictor() *mappingPredictor {
+	return &mappingPredictor{t, make(map[KeyValue]int)}
+}
+
+func (p *mappingPredictor) add(Key string, Value string, CheckValueDuplicate bool) MappingResponse {
+	var kv = KeyValue{Key, Value}
+
+	var ret = validateMapping(kv.Key, kv.Value)
+	if ret == Success && CheckValueDuplicate && p.table.countOf(kv.Key, kv.Value)+p.delta[kv] != 0 {
+		ret = AlreadyExists
+	}
+	if ret == Success {
+		p.delta[kv]++
+	}
+	return ret
+}
+
+func (p *mappingPredictor) remove(Key string, Value string) bool {
+	var kv = KeyValue{Key, Value}
+
+	if p.table.countOf(kv.Key, kv.Value)+p.delta[kv] <= 0 {
+		return false
+	}
+	p.delta[kv]--
+	return true
+}
+
+// predictAdd Returns responses adding the mappings one after another would give, without changing the table
+func (t *mappingTable) predictAdd(Mappings []KeyValue, CheckValueDuplicate bool) []MappingResponse {
+	var p = t.predictor()
+	var ret = make([]MappingResponse, len(Mappings))
+	for x, el := range Mappings {
+		ret[x] = p.add(el.Key, el.Value, CheckValueDuplicate)
+	}
+	return ret
+}
+
+// predictRemove Returns results removing the mappings one after another would give, without changing the table
+func (t *mappingTable) predictRemove(Mappings []KeyValue) []bool {
+	var p = t.predictor()
+	var ret = make([]bool, len(Mappings))
+	for x, el := range Mappings {
+		ret[x] = p.remove(el.Key, el.Value)
+	}
+	return ret
+}
+
+// all Returns mappings ordered by first byte of the key. Mappings sharing first byte keep insertion order,
+// which is the only order affecting matching.
+func (t *mappingTable) all() []KeyValue {
+	var ret = make([]KeyValue, 0, t.count)
+	for x := 0; x < 256; x++ {
+		ret = append(ret, t.byFirst[byte(x)]...)
+	}
+	return ret
+}
+
+func (t *mappingTable) forKey(Key string) []string {
+	if len(Key) == 0 {
+		return nil
+	}
+
+	var ret []string
+	for _, el := range t.byFirst[Key[0]] {
+		if el.Key == Key {
+			ret = append(ret, el.Value)
+		}
+	}
+	return ret
+}
+
+// eachMapping Calls `fn` for mappings whose value is a prefix of input and whose key is a prefix of needle,
+// or equal to it if `Exact` is set, until `fn` returns true
+func (t *mappingTable) eachMapping(In string, Contains string, Exact bool, fn func(KeyValue) bool) bool {
+	if len(Contains) == 0 {
+		return false
+	}
+
+	for _, el := range t.byFirst[Contains[0]] {
+		if Exact && el.Key != Contains || !strings.HasPrefix(Contains, el.Key) || !strings.HasPrefix(In, el.Value) {
+			continue
+		}
+		if fn(el) {
+			return true
+		}
+	}
+	return false
+}
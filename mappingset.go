@@ -0,0 +1,170 @@
+package confusablematcher
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// MappingSet ordered list of key to value mappings which can be stored and passed straight to `New` or `InitConfusableMatcher`
+type MappingSet []KeyValue
+
+const (
+	mappingSetMagic   = "CMMS"
+	mappingSetVersion = 1
+)
+
+// MarshalJSON Encodes the set as an array of `[key, value]` pairs, keeping the order of mappings.
+// JSON strings are UTF-8, use `MarshalBinary` for mappings containing invalid UTF-8.
+func (s MappingSet) MarshalJSON() ([]byte, error) {
+	var pairs = make([][2]string, len(s))
+	for x, el := range s {
+		pairs[x] = [2]string{el.Key, el.Value}
+	}
+	return json.Marshal(pairs)
+}
+
+// UnmarshalJSON Decodes the set from an array of `[key, value]` pairs
+func (s *MappingSet) UnmarshalJSON(Data []byte) error {
+	var pairs [][]string
+	if err := json.Unmarshal(Data, &pairs); err != nil {
+		return err
+	}
+
+	var ret = make(MappingSet, len(pairs))
+	for x, el := range pairs {
+		if len(el) != 2 {
+			return fmt.Errorf("%w: mapping %d has %d elements instead of key and value", ErrInvalidMappingSet, x, len(el))
+		}
+		ret[x] = KeyValue{el[0], el[1]}
+	}
+	*s = ret
+	return nil
+}
+
+// WriteCSV Writes the set as CSV records of key and value, without a header
+func (s MappingSet) WriteCSV(W io.Writer) error {
+	var w = csv.NewWriter(W)
+	for _, el := range s {
+		if err := w.Write([]string{el.Key, el.Value}); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// ReadMappingSetCSV Reads a set written by `WriteCSV`
+//
+// Parameters:
+//
+// - `R` : CSV records of key and value, without a header
+//
+// Returns:
+//
+// - Mapping set in record order
+// - Error if a record does not have exactly two fields or CSV is malformed
+func ReadMappingSetCSV(R io.Reader) (MappingSet, error) {
+	var r = csv.NewReader(R)
+	r.FieldsPerRecord = 2
+
+	var ret MappingSet
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return ret, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, KeyValue{record[0], record[1]})
+	}
+}
+
+// MarshalBinary Encodes the set into a compact versioned format, byte exact for any key and value:
+// magic `CMMS`, version byte, uvarint count, uvarint length prefixed keys and values, and CRC-32 (IEEE) of all preceding bytes
+func (s MappingSet) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(mappingSetMagic)
+	buf.WriteByte(mappingSetVersion)
+
+	var tmp [binary.MaxVarintLen64]byte
+	var writeString = func(In string) {
+		buf.Write(tmp[:binary.PutUvarint(tmp[:], uint64(len(In)))])
+		buf.WriteString(In)
+	}
+
+	buf.Write(tmp[:binary.PutUvarint(tmp[:], uint64(len(s)))])
+	for _, el := range s {
+		writeString(el.Key)
+		writeString(el.Value)
+	}
+
+	binary.LittleEndian.PutUint32(tmp[:4], crc32.ChecksumIEEE(buf.Bytes()))
+	buf.Write(tmp[:4])
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary Decodes the set encoded by `MarshalBinary`, returning `ErrInvalidMappingSet` if data is corrupted
+// or of unsupported version
+func (s *MappingSet) UnmarshalBinary(Data []byte) error {
+	if len(Data) < len(mappingSetMagic)+1+4 || string(Data[:len(mappingSetMagic)]) != mappingSetMagic {
+		return fmt.Errorf("%w: not a mapping set", ErrInvalidMappingSet)
+	}
+	if Data[len(mappingSetMagic)] != mappingSetVersion {
+		return fmt.Errorf("%w: unsupported version %d", ErrInvalidMappingSet, Data[len(mappingSetMagic)])
+	}
+
+	var body = Data[:len(Data)-4]
+	if crc32.ChecksumIEEE(body) != binary.LittleEndian.Uint32(Data[len(Data)-4:]) {
+		return fmt.Errorf("%w: checksum mismatch", ErrInvalidMappingSet)
+	}
+	body = body[len(mappingSetMagic)+1:]
+
+	var readUvarint = func() (uint64, bool) {
+		v, n := binary.Uvarint(body)
+		if n <= 0 {
+			return 0, false
+		}
+		body = body[n:]
+		return v, true
+	}
+	var readString = func() (string, bool) {
+		l, ok := readUvarint()
+		if !ok || l > uint64(len(body)) {
+			return "", false
+		}
+		var ret = string(body[:l])
+		body = body[l:]
+		return ret, true
+	}
+
+	count, ok := readUvarint()
+	// Every mapping takes at least two bytes of length prefixes
+	if !ok || count > uint64(len(body))/2 {
+		return fmt.Errorf("%w: invalid mapping count", ErrInvalidMappingSet)
+	}
+
+	var ret = make(MappingSet, count)
+	for x := range ret {
+		key, ok := readString()
+		if !ok {
+			return fmt.Errorf("%w: truncated key of mapping %d", ErrInvalidMappingSet, x)
+		}
+		value, ok := readString()
+		if !ok {
+			return fmt.Errorf("%w: truncated value of mapping %d", ErrInvalidMappingSet, x)
+		}
+		ret[x] = KeyValue{key, value}
+	}
+	if len(body) != 0 {
+		return fmt.Errorf("%w: %d trailing bytes", ErrInvalidMappingSet, len(body))
+	}
+
+	*s = ret
+	return nil
+}
@@ -0,0 +1,96 @@
+package confusablematcher
+
+import "sort"
+
+// Match describes a single match found in input string
+type Match struct {
+	// Index Byte offset of the match in input string
+	Index int
+	// Length Length of the match in bytes
+	Length int
+	// Needle Index of the needle which matched when searching for multiple needles, 0 otherwise
+	Needle int
+	// RuneIndex Offset of the match in runes
+	RuneIndex int
+	// RuneLength Length of the match in runes
+	RuneLength int
+	// UTF16Index Offset of the match in UTF-16 code units
+	UTF16Index int
+	// UTF16Length Length of the match in UTF-16 code units
+	UTF16Length int
+}
+
+// Offsets Returns index and length of the match in specified unit
+func (m Match) Offsets(U Unit) (int, int) {
+	switch U {
+	case Runes:
+		return m.RuneIndex, m.RuneLength
+	case UTF16:
+		return m.UTF16Index, m.UTF16Length
+	}
+	return m.Index, m.Length
+}
+
+// Direction direction in which matches are searched for
+type Direction int
+
+const (
+	// Forward Finds first match at or after start index
+	Forward Direction = 0
+	// Backward Finds last match at or after start index
+	Backward Direction = 1
+)
+
+// Options options accepted by every search function. Zero value searches forward from the start of input string,
+// using matcher's ignore list, same as `IndexOf` with `MatchRepeating` unset.
+type Options struct {
+	// MatchRepeating Should it match repeating substrings in the mapping (without consuming the 'contains' portion of operation)
+	MatchRepeating bool
+	// StartIndex Starting index, expressed in `Unit`
+	StartIndex int
+	// Unit Unit of `StartIndex` and `MaxLength`, bytes by default. Returned matches carry offsets in every unit.
+	Unit Unit
+	// IgnoreList Overrides matcher's ignore list for this call if not `nil`, empty slice ignores nothing
+	IgnoreList []string
+	// MaxLength Maximum length of a match expressed in `Unit`, 0 for no limit.
+	// Longer ways of matching the needle are skipped in favour of shorter ones, so a limited search runs in Go on the mirrored mappings.
+	MaxLength int
+	// Direction Whether to find first or last match. When finding multiple matches, `Backward` returns them from last to first
+	// and `Max` keeps the last ones.
+	Direction Direction
+	// Overlapping Whether next match may start inside previous one. If not set, search continues after the end of previous match.
+	// Only used when finding multiple matches.
+	Overlapping bool
+	// Max Maximum number of matches to return, 0 for no limit. Only used when finding multiple matches.
+	Max int
+	// Boundary Requires matches to start and end at a boundary, such as `WordBoundary`, `SpaceBoundary` or one made by `BoundarySet`.
+	// Ignore list entries next to a match are skipped when looking for the boundary, as the matcher skips them inside of it,
+	// unless they contain a boundary character such as whitespace, which counts as a boundary.
+	// `nil` for no requirement. A search requiring boundaries runs in Go on the mirrored mappings.
+	Boundary Boundary
+}
+
+func decodeMatch(ret uint64) Match {
+	return Match{Index: int(int32(ret & 0xFFFFFFFF)), Length: int(int32(ret >> 32))}
+}
+
+// sortMatches Orders matches by index, then by needle
+func sortMatches(Matches []Match) {
+	// Matches of a single needle are found in order already, which is checked without allocating
+	for x := 1; x < len(Matches); x++ {
+		if matchLess(Matches[x], Matches[x-1]) {
+			sort.Slice(Matches, func(i, j int) bool {
+				return matchLess(Matches[i], Matches[j])
+			})
+			return
+		}
+	}
+}
+
+// matchLess Reports whether match `A` comes before `B` when ordered by index and then by needle
+func matchLess(A Match, B Match) bool {
+	if A.Index != B.Index {
+		return A.Index < B.Index
+	}
+	return A.Needle < B.Needle
+}
@@ -0,0 +1,548 @@
+//go:build cgo && !purego
+
+package confusablematcher
+
+/*
+#include "ConfusableMatcher/Export.h"
+#include <stdlib.h>
+#include <string.h>
+#cgo LDFLAGS: -L. -lconfusablematcher -lstdc++
+
+typedef struct {
+	uint64_t Match;
+	int Needle;
+} cmResult;
+
+typedef struct {
+	cmResult *Data;
+	int Size;
+	int Capacity;
+} cmResults;
+
+// appendMatches Calls StringIndexOf repeatedly and appends every match of the needle to Out.
+// Returns false if memory could not be allocated, Out.Data must be freed by caller in either case.
+static bool appendMatches(cmResults *Out, CMHandle CM, char *In, int InLen, char *Contains, int Needle, bool MatchRepeating, int StartIndex, CMListHandle IgnoreList, bool Overlapping, int Max) {
+	cmResult *tmp;
+
+	while (StartIndex <= InLen && (Max <= 0 || Out->Size < Max)) {
+		uint64_t res = StringIndexOf(CM, In, Contains, MatchRepeating, StartIndex, IgnoreList);
+		int index = (int)(res & 0xFFFFFFFF), length = (int)(res >> 32);
+		if (index < StartIndex)
+			break;
+
+		if (Out->Size == Out->Capacity) {
+			Out->Capacity = Out->Capacity == 0 ? 16 : Out->Capacity * 2;
+			tmp = realloc(Out->Data, Out->Capacity * sizeof(cmResult));
+			if (tmp == NULL)
+				return false;
+			Out->Data = tmp;
+		}
+		Out->Data[Out->Size].Match = res;
+		Out->Data[Out->Size].Needle = Needle;
+		Out->Size++;
+
+		StartIndex = Overlapping || length == 0 ? index + 1 : index + length;
+	}
+
+	return true;
+}
+
+// collectMatches Appends matches of every needle to Out, stopping once Max matches were collected.
+// Needles are NeedleCount NUL terminated strings stored consecutively.
+// In is made of NUL separated segments which are searched separately, as no match can span a NUL byte.
+static bool collectMatches(cmResults *Out, CMHandle CM, char *In, int InLen, char *Needles, int NeedleCount, bool MatchRepeating, int StartIndex, CMListHandle IgnoreList, bool Overlapping, int Max) {
+	char *needle = Needles;
+	for (int x = 0; x < NeedleCount && (Max <= 0 || Out->Size < Max); x++, needle += strlen(needle) + 1) {
+		// Empty needle matches at the start of input only
+		if (needle[0] == 0) {
+			if (!appendMatches(Out, CM, In, InLen, needle, x, MatchRepeating, StartIndex, IgnoreList, Overlapping, Max))
+				return false;
+			continue;
+		}
+
+		for (int seg = 0; seg <= InLen && (Max <= 0 || Out->Size < Max);) {
+			int segLen = strlen(In + seg), first = Out->Size;
+			int start = StartIndex > seg ? StartIndex - seg : 0;
+
+			if (!appendMatches(Out, CM, In + seg, segLen, needle, x, MatchRepeating, start, IgnoreList, Overlapping, Max))
+				return false;
+			for (int y = first; y < Out->Size; y++)
+				Out->Data[y].Match = (Out->Data[y].Match & 0xFFFFFFFF00000000ull) | (uint32_t)((int)(Out->Data[y].Match & 0xFFFFFFFF) + seg);
+
+			seg += segLen + 1;
+		}
+	}
+
+	return true;
+}
+
+// findAny Returns matches collected by collectMatches, Size is -1 if memory could not be allocated.
+// Data must be freed by caller in either case.
+static cmResults findAny(CMHandle CM, char *In, int InLen, char *Needles, int NeedleCount, bool MatchRepeating, int StartIndex, CMListHandle IgnoreList, bool Overlapping, int Max) {
+	cmResults out = {NULL, 0, 0};
+	if (!collectMatches(&out, CM, In, InLen, Needles, NeedleCount, MatchRepeating, StartIndex, IgnoreList, Overlapping, Max))
+		out.Size = -1;
+	return out;
+}
+
+// lastIndexOf Returns last match starting at or after StartIndex, following matches one after another
+static uint64_t lastIndexOf(CMHandle CM, char *In, char *Contains, bool MatchRepeating, int StartIndex, CMListHandle IgnoreList) {
+	uint64_t ret = StringIndexOf(CM, In, Contains, MatchRepeating, StartIndex, IgnoreList);
+	int index = (int)(ret & 0xFFFFFFFF);
+
+	while (index >= 0 && index >= StartIndex) {
+		uint64_t next = StringIndexOf(CM, In, Contains, MatchRepeating, index + 1, IgnoreList);
+		int nextIndex = (int)(next & 0xFFFFFFFF);
+		if (nextIndex <= index)
+			break;
+
+		ret = next;
+		index = nextIndex;
+	}
+
+	return ret;
+}
+
+// segmentIndexOf Finds first or last match in NUL separated segments of In, as no match can span a NUL byte
+static uint64_t segmentIndexOf(CMHandle CM, char *In, int InLen, char *Contains, bool MatchRepeating, int StartIndex, CMListHandle IgnoreList, bool Backward) {
+	uint64_t ret = 0xFFFFFFFFFFFFFFFFull;
+
+	// Empty needle matches at the start of input only
+	if (Contains[0] == 0)
+		return StringIndexOf(CM, In, Contains, MatchRepeating, StartIndex, IgnoreList);
+
+	for (int seg = 0; seg <= InLen;) {
+		int segLen = strlen(In + seg);
+		int start = StartIndex > seg ? StartIndex - seg : 0;
+
+		uint64_t res = Backward ? lastIndexOf(CM, In + seg, Contains, MatchRepeating, start, IgnoreList) : StringIndexOf(CM, In + seg, Contains, MatchRepeating, start, IgnoreList);
+		int index = (int)(res & 0xFFFFFFFF);
+		if (index >= 0) {
+			ret = (res & 0xFFFFFFFF00000000ull) | (uint32_t)(index + seg);
+			if (!Backward)
+				break;
+		}
+
+		seg += segLen + 1;
+	}
+
+	return ret;
+}
+
+// addMappings Adds Count key and value pairs stored consecutively in Data as NUL terminated strings
+static void addMappings(CMHandle CM, char *Data, int Count, bool CheckValueDuplicate, int *Out) {
+	for (int x = 0; x < Count; x++) {
+		char *key = Data;
+		Data += strlen(Data) + 1;
+		char *value = Data;
+		Data += strlen(Data) + 1;
+
+		Out[x] = AddMapping(CM, key, value, CheckValueDuplicate);
+	}
+}
+
+// removeMappings Removes Count key and value pairs stored like in addMappings
+static void removeMappings(CMHandle CM, char *Data, int Count, bool *Out) {
+	for (int x = 0; x < Count; x++) {
+		char *key = Data;
+		Data += strlen(Data) + 1;
+		char *value = Data;
+		Data += strlen(Data) + 1;
+
+		Out[x] = RemoveMapping(CM, key, value);
+	}
+}
+*/
+import "C"
+import (
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// buffers Go memory reused for passing NUL terminated strings to native code, which avoids C allocation on every search
+var buffers = sync.Pool{New: func() any { return new([]byte) }}
+
+// maxPooledBuffer Buffers larger than this are left to garbage collector
+const maxPooledBuffer = 1 << 20
+
+func getBuffer() *[]byte {
+	return buffers.Get().(*[]byte)
+}
+
+func putBuffer(Buf *[]byte) {
+	if cap(*Buf) <= maxPooledBuffer {
+		buffers.Put(Buf)
+	}
+}
+
+// cString Appends NUL terminated copy of string to buffer
+func cString(Buf *[]byte, In string) {
+	*Buf = append(append(*Buf, In...), 0)
+}
+
+// core native confusable matcher together with Go side copy of its mappings. Engines which differ only
+// in their ignore list share it, it is freed once the last of them is freed.
+type core struct {
+	matcher  C.CMHandle
+	mappings *mappingTable
+	refs     int32
+}
+
+// engine native confusable matcher and ignore list together with Go side copy of both.
+// Native code only sees NUL terminated strings, so mappings and ignore list entries containing 0x00 byte are kept
+// in the Go copy only, and searches which can reach them run in Go, see `inGo`.
+type engine struct {
+	*core
+	ignoreList C.CMListHandle
+	ignore     []string
+	nulIgnore  bool
+}
+
+func newEngine(InputMap []KeyValue, AddDefaultValues bool) (*engine, error) {
+	var cmMap C.CMMap
+
+	var tmp C.CMKV
+	var structSz = int(unsafe.Sizeof(tmp))
+	cmMap.Kv = (*C.CMKV)(C.malloc((C.ulong)(len(InputMap) * structSz * 5)))
+	defer C.free(unsafe.Pointer(cmMap.Kv))
+
+	var size = 0
+	for _, el := range InputMap {
+		if hasNUL(el.Key) || hasNUL(el.Value) {
+			continue
+		}
+
+		var cmKV C.CMKV
+		cmKV.Key = C.CString(el.Key)
+		defer C.free(unsafe.Pointer(cmKV.Key))
+		cmKV.Value = C.CString(el.Value)
+		defer C.free(unsafe.Pointer(cmKV.Value))
+
+		var ptr = unsafe.Pointer(uintptr(unsafe.Pointer(cmMap.Kv)) + uintptr(size*structSz))
+		*((*C.CMKV)(ptr)) = cmKV
+		size++
+	}
+
+	cmMap.Size = C.uint(size)
+
+	var e = &engine{core: &core{mappings: newMappingTable(InputMap, AddDefaultValues), refs: 1}}
+	e.ignoreList = constructIgnoreList(nil)
+	if e.ignoreList == nil {
+		return nil, ErrInitFailed
+	}
+	e.matcher = C.InitConfusableMatcher(cmMap, (C.bool)(AddDefaultValues))
+	if e.matcher == nil {
+		C.FreeIgnoreList(e.ignoreList)
+		return nil, ErrInitFailed
+	}
+	return e, nil
+}
+
+// withIgnoreList Returns engine sharing native matcher with this one and searching with another ignore list
+func (e *engine) withIgnoreList(In []string) (*engine, error) {
+	var list = constructIgnoreList(In)
+	if list == nil {
+		return nil, ErrInitFailed
+	}
+
+	atomic.AddInt32(&e.refs, 1)
+	return &engine{core: e.core, ignoreList: list, ignore: In, nulIgnore: anyNUL(In)}, nil
+}
+
+// shared Reports whether native matcher is used by other engines too, in which case its mappings must not be changed
+func (e *engine) shared() bool {
+	return atomic.LoadInt32(&e.refs) != 1
+}
+
+// constructIgnoreList Returns `nil` if native ignore list could not be constructed. Entries containing 0x00 byte are left out.
+func constructIgnoreList(In []string) C.CMListHandle {
+	var tmp *C.char
+	var ptrSz = int(unsafe.Sizeof(&tmp))
+	var list = (**C.char)(C.malloc((C.ulong)(len(In) * ptrSz)))
+	defer C.free(unsafe.Pointer(list))
+
+	var size = 0
+	for _, el := range In {
+		if hasNUL(el) {
+			continue
+		}
+
+		var str = C.CString(el)
+		defer C.free(unsafe.Pointer(str))
+
+		var ptr = unsafe.Pointer(uintptr(unsafe.Pointer(list)) + uintptr(size*ptrSz))
+		*((**C.char)(ptr)) = str
+		size++
+	}
+
+	return C.ConstructIgnoreList(list, (C.int)(size))
+}
+
+func anyNUL(In []string) bool {
+	for _, el := range In {
+		if hasNUL(el) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *engine) free() {
+	C.FreeIgnoreList(e.ignoreList)
+	if atomic.AddInt32(&e.refs, -1) == 0 {
+		C.FreeConfusableMatcher(e.matcher)
+	}
+}
+
+func (e *engine) setIgnoreList(In []string) error {
+	var list = constructIgnoreList(In)
+	if list == nil {
+		return ErrInitFailed
+	}
+
+	C.FreeIgnoreList(e.ignoreList)
+	e.ignoreList = list
+	e.ignore = In
+	e.nulIgnore = anyNUL(In)
+	return nil
+}
+
+// inGo Reports whether search has to run in Go, because native code cannot see all of it.
+// Mapping keys containing 0x00 byte can only match a needle containing it, while mapping values and ignore list entries
+// containing it can only match input containing it, so other searches run natively even if such mappings exist.
+func (e *engine) inGo(Options Options, In string, NulNeedles bool) bool {
+	if Options.MaxLength > 0 || Options.Boundary != nil || NulNeedles {
+		return true
+	}
+	if !hasNUL(In) {
+		return false
+	}
+	if e.mappings.nul != 0 {
+		return true
+	}
+	if Options.IgnoreList != nil {
+		return anyNUL(Options.IgnoreList)
+	}
+	return e.nulIgnore
+}
+
+// ignoreListFor Returns native ignore list to search with and whether it was constructed for the call and must be freed
+func (e *engine) ignoreListFor(Options Options) (C.CMListHandle, bool, error) {
+	if Options.IgnoreList == nil {
+		return e.ignoreList, false, nil
+	}
+
+	var list = constructIgnoreList(Options.IgnoreList)
+	if list == nil {
+		return nil, false, ErrInitFailed
+	}
+	return list, true, nil
+}
+
+// searchesInGo Reports whether `indexOf` runs in Go and gives up once `Limit` is exceeded, see `searchLimit.native`
+func (e *engine) searchesInGo(In string, Contains string, Options Options, Limit *searchLimit) bool {
+	return !Limit.native() || e.inGo(Options, In, hasNUL(Contains))
+}
+
+// nativeStart Returns start index which fits native int, with the same meaning for native search.
+// Indexes past the end of input are not, callers handle them before native call.
+func nativeStart(StartIndex int) C.int {
+	if StartIndex < 0 {
+		return 0
+	}
+	return (C.int)(StartIndex)
+}
+
+// indexOf Returns first or last match. Native search runs to completion regardless of `Limit`, see `searchesInGo`.
+func (e *engine) indexOf(In string, Contains string, Options Options, StartIndex int, Limit *searchLimit) (Match, error) {
+	if e.searchesInGo(In, Contains, Options, Limit) {
+		return searchFirst(e.mappings, e.ignore, In, Contains, Options, StartIndex, Limit)
+	}
+
+	// Nothing but empty needle matches past the end of input, and the index may not fit native int
+	if StartIndex > len(In) {
+		if len(Contains) == 0 {
+			return Match{}, nil
+		}
+		return Match{Index: -1, Length: -1}, nil
+	}
+
+	list, owned, err := e.ignoreListFor(Options)
+	if err != nil {
+		return Match{}, err
+	}
+	if owned {
+		defer C.FreeIgnoreList(list)
+	}
+
+	// Input and needle are passed in Go memory, which native code does not keep
+	var buf = getBuffer()
+	defer putBuffer(buf)
+	*buf = (*buf)[:0]
+	cString(buf, In)
+	cString(buf, Contains)
+	var inPtr = (*C.char)(unsafe.Pointer(&(*buf)[0]))
+	var containsPtr = (*C.char)(unsafe.Pointer(&(*buf)[len(In)+1]))
+
+	return decodeMatch(uint64(C.segmentIndexOf(e.matcher, inPtr, (C.int)(len(In)), containsPtr, (C.bool)(Options.MatchRepeating), nativeStart(StartIndex), list, (C.bool)(Options.Direction == Backward)))), nil
+}
+
+// findAny Returns matches of every needle, grouped by needle
+func (e *engine) findAny(In string, Needles []string, Options Options, StartIndex int) ([]Match, error) {
+	if e.inGo(Options, In, anyNUL(Needles)) {
+		return searchAll(e.mappings, e.ignore, In, Needles, Options, StartIndex), nil
+	}
+	if StartIndex > len(In) || len(Needles) == 0 {
+		return nil, nil
+	}
+
+	list, owned, err := e.ignoreListFor(Options)
+	if err != nil {
+		return nil, err
+	}
+	if owned {
+		defer C.FreeIgnoreList(list)
+	}
+
+	// Input and needles are passed in Go memory like in `indexOf`, only matches are collected in native memory
+	var buf = getBuffer()
+	defer putBuffer(buf)
+	*buf = (*buf)[:0]
+	cString(buf, In)
+	for _, el := range Needles {
+		cString(buf, el)
+	}
+	var inPtr = (*C.char)(unsafe.Pointer(&(*buf)[0]))
+	var needlesPtr = (*C.char)(unsafe.Pointer(&(*buf)[len(In)+1]))
+
+	var out = C.findAny(e.matcher, inPtr, (C.int)(len(In)), needlesPtr, (C.int)(len(Needles)), (C.bool)(Options.MatchRepeating), nativeStart(StartIndex), list, (C.bool)(Options.Overlapping), (C.int)(Options.Max))
+	defer C.free(unsafe.Pointer(out.Data))
+	if out.Size < 0 {
+		return nil, ErrOutOfMemory
+	}
+	if out.Size == 0 {
+		return nil, nil
+	}
+
+	var res = unsafe.Slice(out.Data, int(out.Size))
+	var ret = make([]Match, len(res))
+	for x := range ret {
+		ret[x] = decodeMatch(uint64(res[x].Match))
+		ret[x].Needle = int(res[x].Needle)
+	}
+	return ret, nil
+}
+
+func (e *engine) explain(In string, Contains string, Options Options, Index int, Length int) ([]Segment, bool) {
+	return explain(e.mappings, ignoreListFor(e.ignore, Options), In, Contains, Options.MatchRepeating, Options.Boundary, Index, Length)
+}
+
+func (e *engine) addMapping(Key string, Value string, CheckValueDuplicate bool) MappingResponse {
+	if hasNUL(Key) || hasNUL(Value) {
+		return e.mappings.add(Key, Value, CheckValueDuplicate)
+	}
+
+	var keyPtr = C.CString(Key)
+	defer C.free(unsafe.Pointer(keyPtr))
+	var valPtr = C.CString(Value)
+	defer C.free(unsafe.Pointer(valPtr))
+
+	var ret = MappingResponse(C.AddMapping(e.matcher, keyPtr, valPtr, C.bool(CheckValueDuplicate)))
+	if ret == Success {
+		e.mappings.add(Key, Value, false)
+	}
+	return ret
+}
+
+func (e *engine) removeMapping(Key string, Value string) bool {
+	if hasNUL(Key) || hasNUL(Value) {
+		return e.mappings.remove(Key, Value)
+	}
+
+	var keyPtr = C.CString(Key)
+	defer C.free(unsafe.Pointer(keyPtr))
+	var valPtr = C.CString(Value)
+	defer C.free(unsafe.Pointer(valPtr))
+
+	var ret = bool(C.RemoveMapping(e.matcher, keyPtr, valPtr))
+	if ret {
+		e.mappings.remove(Key, Value)
+	}
+	return ret
+}
+
+// packMappings Returns keys and values without 0x00 bytes, stored consecutively as NUL terminated strings in C memory
+func packMappings(Mappings []KeyValue) (unsafe.Pointer, int) {
+	var buf []byte
+	var count = 0
+	for _, el := range Mappings {
+		if hasNUL(el.Key) || hasNUL(el.Value) {
+			continue
+		}
+		buf = append(append(buf, el.Key...), 0)
+		buf = append(append(buf, el.Value...), 0)
+		count++
+	}
+	return C.CBytes(buf), count
+}
+
+// addMappings Adds mappings without 0x00 bytes in a single native call, others are added to Go side copy in order
+func (e *engine) addMappings(Mappings []KeyValue, CheckValueDuplicate bool) []MappingResponse {
+	if len(Mappings) == 0 {
+		return nil
+	}
+
+	var data, count = packMappings(Mappings)
+	defer C.free(data)
+
+	var out = make([]C.int, count+1)
+	if count != 0 {
+		C.addMappings(e.matcher, (*C.char)(data), (C.int)(count), C.bool(CheckValueDuplicate), &out[0])
+	}
+
+	var ret = make([]MappingResponse, len(Mappings))
+	for x, el := range Mappings {
+		if hasNUL(el.Key) || hasNUL(el.Value) {
+			ret[x] = e.mappings.add(el.Key, el.Value, CheckValueDuplicate)
+			continue
+		}
+
+		ret[x] = MappingResponse(out[0])
+		out = out[1:]
+		if ret[x] == Success {
+			e.mappings.add(el.Key, el.Value, false)
+		}
+	}
+	return ret
+}
+
+// removeMappings Removes mappings without 0x00 bytes in a single native call, others are removed from Go side copy in order
+func (e *engine) removeMappings(Mappings []KeyValue) []bool {
+	if len(Mappings) == 0 {
+		return nil
+	}
+
+	var data, count = packMappings(Mappings)
+	defer C.free(data)
+
+	var out = make([]C.bool, count+1)
+	if count != 0 {
+		C.removeMappings(e.matcher, (*C.char)(data), (C.int)(count), &out[0])
+	}
+
+	var ret = make([]bool, len(Mappings))
+	for x, el := range Mappings {
+		if hasNUL(el.Key) || hasNUL(el.Value) {
+			ret[x] = e.mappings.remove(el.Key, el.Value)
+			continue
+		}
+
+		ret[x] = bool(out[0])
+		out = out[1:]
+		if ret[x] {
+			e.mappings.remove(el.Key, el.Value)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,124 @@
+//go:build cgo && !purego
+
+package confusablematcher
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// FuzzNativeIndexOf Checks that native search finds the same match start as the Go search over mirrored mappings,
+// which purego builds use. Native matcher may return any of the lengths a match can have, like in `Test2`.
+func FuzzNativeIndexOf(f *testing.F) {
+	fuzzIndexOfSeeds(f)
+
+	f.Fuzz(func(t *testing.T, Mappings string, Ignore string, In string, Contains string, MatchRepeating bool, StartIndex int) {
+		var m = fuzzMatcher(t, Mappings, Ignore, In, Contains)
+		if m == nil {
+			return
+		}
+		defer m.Close()
+
+		s, err := m.Snapshot()
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer s.Release()
+
+		s.lock.RLock()
+		defer s.lock.RUnlock()
+
+		var e = s.engine
+		var mappings = e.mappings.all()
+		for _, direction := range []Direction{Forward, Backward} {
+			var options = Options{MatchRepeating: MatchRepeating, StartIndex: StartIndex, Direction: direction}
+			match, err := e.indexOf(In, Contains, options, StartIndex, nil)
+			assert.NoError(t, err)
+			expected, _ := searchFirst(e.mappings, e.ignore, In, Contains, options, StartIndex, nil)
+			assert.Equal(t, expected.Index, match.Index, "direction %d", direction)
+			if match.Index >= 0 {
+				assert.True(t, oracleLengths(mappings, e.ignore, In, Contains, MatchRepeating, match.Index)[match.Length], "direction %d", direction)
+			} else {
+				assert.Equal(t, -1, match.Length, "direction %d", direction)
+			}
+		}
+	})
+}
+
+func TestNULMappingsSearchNatively(t *testing.T) {
+	matcher, err := New([]KeyValue{{"A", "4"}, {"A", "\x02\x00"}}, true)
+	assert.NoError(t, err)
+	defer matcher.Close()
+	assert.NoError(t, matcher.SetIgnoreList([]string{"_", "\x02\x00_"}))
+
+	s, err := matcher.Snapshot()
+	assert.NoError(t, err)
+	defer s.Release()
+
+	// Mapping value and ignore list entry containing 0x00 byte can only match input containing it
+	assert.False(t, s.engine.inGo(Options{}, "b4d", false))
+	assert.True(t, s.engine.inGo(Options{}, "b\x02\x00d", false))
+	assert.True(t, s.engine.inGo(Options{}, "b4d", true))
+	assert.False(t, s.engine.inGo(Options{IgnoreList: []string{"\x00"}}, "b4d", false))
+
+	for _, el := range []struct {
+		in       string
+		expected []int
+	}{
+		{"xb4_d", []int{1, 4}},
+		{"xb\x02\x00d", []int{1, 4}},
+		{"xb\x02\x00_d", []int{1, 5}},
+	} {
+		index, length, err := matcher.IndexOf(el.in, "BAD", false, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, el.expected, []int{index, length}, "%q", el.in)
+	}
+}
+
+func TestBackgroundSearchesLimited(t *testing.T) {
+	matcher, err := New([]KeyValue{{"A", "a"}, {"A", "aa"}}, false)
+	assert.NoError(t, err)
+	defer matcher.Close()
+	assert.NoError(t, matcher.SetBudget(Budget{Time: time.Hour}))
+
+	var in = strings.Repeat("a", 1<<16)
+
+	// Native search does not check context while it runs
+	_, _, err = matcher.IndexOfContext(&cancelAfter{Context: context.Background(), checks: 1}, in, "AB", false, 0)
+	assert.NoError(t, err)
+
+	// Once no more native searches may be left running, search runs in Go and stops
+	for x := 0; x < cap(backgroundSearches); x++ {
+		backgroundSearches <- struct{}{}
+	}
+	_, _, err = matcher.IndexOfContext(&cancelAfter{Context: context.Background(), checks: 1}, in, "AB", false, 0)
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, cap(backgroundSearches), len(backgroundSearches))
+
+	for x := 0; x < cap(backgroundSearches); x++ {
+		<-backgroundSearches
+	}
+	index, length, err := matcher.IndexOf("bbaa", "A", false, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{2, 1}, []int{index, length})
+}
+
+func TestFindAllBytesAllocations(t *testing.T) {
+	matcher, err := New([]KeyValue{{"A", "4"}, {"S", "$"}}, true)
+	assert.NoError(t, err)
+	defer matcher.Close()
+
+	// Input and needle go through the reused buffer, only the returned matches are allocated
+	var in = []byte(strings.Repeat("a$ ä4$ ", 20))
+	var matches []Match
+	var allocs = testing.AllocsPerRun(100, func() {
+		matches, err = matcher.FindAllBytes(in, "AS", Options{})
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 40, len(matches))
+	assert.Equal(t, 1.0, allocs)
+}
@@ -0,0 +1,38 @@
+package confusablematcher
+
+import "sync"
+
+// Needles list of needles to be searched for with `FindAny`. Needles are passed to native code together with input string,
+// so they hold no native memory. Needles may be shared between goroutines.
+type Needles struct {
+	needles []string
+	closed  bool
+	lock    sync.RWMutex
+}
+
+// CompileNeedles Prepares needles for searching with `FindAny`
+//
+// Parameters:
+//
+// - `In` : Needles to search for
+//
+// Returns:
+//
+// - Compiled needles
+func CompileNeedles(In []string) *Needles {
+	return &Needles{needles: append([]string(nil), In...)}
+}
+
+// Needles Returns copy of needles which were compiled, indexed by `Match.Needle`
+func (n *Needles) Needles() []string {
+	return append([]string(nil), n.needles...)
+}
+
+// Close Marks needles closed. Searching with them afterwards returns `ErrClosed`, calling `Close` again does nothing.
+func (n *Needles) Close() error {
+	n.lock.Lock()
+	n.closed = true
+	n.lock.Unlock()
+
+	return nil
+}
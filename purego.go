@@ -0,0 +1,94 @@
+//go:build !cgo || purego
+
+package confusablematcher
+
+import "sync/atomic"
+
+// core mappings of the matcher, shared by engines which differ only in their ignore list
+type core struct {
+	mappings *mappingTable
+	refs     int32
+}
+
+// engine pure Go confusable matcher, used when cgo is disabled or `purego` build tag is set.
+// It follows documented semantics of the native matcher.
+type engine struct {
+	*core
+	ignore []string
+}
+
+func newEngine(InputMap []KeyValue, AddDefaultValues bool) (*engine, error) {
+	return &engine{core: &core{mappings: newMappingTable(InputMap, AddDefaultValues), refs: 1}}, nil
+}
+
+// withIgnoreList Returns engine sharing mappings with this one and searching with another ignore list
+func (e *engine) withIgnoreList(In []string) (*engine, error) {
+	atomic.AddInt32(&e.refs, 1)
+	return &engine{core: e.core, ignore: In}, nil
+}
+
+// shared Reports whether mappings are used by other engines too, in which case they must not be changed
+func (e *engine) shared() bool {
+	return atomic.LoadInt32(&e.refs) != 1
+}
+
+func (e *engine) free() {
+	atomic.AddInt32(&e.refs, -1)
+	e.core = nil
+	e.ignore = nil
+}
+
+func (e *engine) setIgnoreList(In []string) error {
+	e.ignore = In
+	return nil
+}
+
+// searchesInGo Reports whether `indexOf` runs in Go and gives up once `Limit` is exceeded, which it always does
+func (e *engine) searchesInGo(In string, Contains string, Options Options, Limit *searchLimit) bool {
+	return true
+}
+
+func (e *engine) indexOf(In string, Contains string, Options Options, StartIndex int, Limit *searchLimit) (Match, error) {
+	return searchFirst(e.mappings, e.ignore, In, Contains, Options, StartIndex, Limit)
+}
+
+// findAny Returns matches of every needle, grouped by needle
+func (e *engine) findAny(In string, Needles []string, Options Options, StartIndex int) ([]Match, error) {
+	return searchAll(e.mappings, e.ignore, In, Needles, Options, StartIndex), nil
+}
+
+func (e *engine) explain(In string, Contains string, Options Options, Index int, Length int) ([]Segment, bool) {
+	return explain(e.mappings, ignoreListFor(e.ignore, Options), In, Contains, Options.MatchRepeating, Options.Boundary, Index, Length)
+}
+
+func (e *engine) addMapping(Key string, Value string, CheckValueDuplicate bool) MappingResponse {
+	return e.mappings.add(Key, Value, CheckValueDuplicate)
+}
+
+func (e *engine) removeMapping(Key string, Value string) bool {
+	return e.mappings.remove(Key, Value)
+}
+
+func (e *engine) addMappings(Mappings []KeyValue, CheckValueDuplicate bool) []MappingResponse {
+	if len(Mappings) == 0 {
+		return nil
+	}
+
+	var ret = make([]MappingResponse, len(Mappings))
+	for x, el := range Mappings {
+		ret[x] = e.addMapping(el.Key, el.Value, CheckValueDuplicate)
+	}
+	return ret
+}
+
+func (e *engine) removeMappings(Mappings []KeyValue) []bool {
+	if len(Mappings) == 0 {
+		return nil
+	}
+
+	var ret = make([]bool, len(Mappings))
+	for x, el := range Mappings {
+		ret[x] = e.removeMapping(el.Key, el.Value)
+	}
+	return ret
+}
@@ -0,0 +1,82 @@
+package confusablematcher
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Replace Replaces every match of any of the needles in input string
+//
+// Parameters:
+//
+// - `In` : Input string
+// - `Needles` : Needles returned by `CompileNeedles`
+// - `Options` : Search options, see `FindAny`
+// - `Replacer` : Returns replacement for a match. Overlapping matches are merged into a single match spanning all of them,
+// which carries needle of the earliest one. Adjacent matches are replaced separately.
+//
+// Returns:
+//
+// - Input string with matches replaced
+// - Error if operation could not be performed
+func (m *Matcher) Replace(In string, Needles *Needles, Options Options, Replacer func(Match) string) (string, error) {
+	matches, err := m.FindAny(In, Needles, Options)
+	if err != nil || len(matches) == 0 {
+		return In, err
+	}
+	if Options.Direction == Backward {
+		sortMatches(matches)
+	}
+
+	var sb strings.Builder
+	var last = 0
+	for _, match := range mergeMatches(matches) {
+		sb.WriteString(In[last:match.Index])
+		sb.WriteString(Replacer(match))
+		last = match.Index + match.Length
+	}
+	sb.WriteString(In[last:])
+
+	return sb.String(), nil
+}
+
+// Censor Masks every match of any of the needles in input string
+//
+// Parameters:
+//
+// - `In` : Input string
+// - `Needles` : Needles returned by `CompileNeedles`
+// - `Options` : Search options, see `FindAny`
+// - `Mask` : Rune to replace each rune of the match with
+//
+// Returns:
+//
+// - Input string with matches masked
+// - Error if operation could not be performed
+func (m *Matcher) Censor(In string, Needles *Needles, Options Options, Mask rune) (string, error) {
+	return m.Replace(In, Needles, Options, func(match Match) string {
+		var count = utf8.RuneCountInString(In[match.Index : match.Index+match.Length])
+		return strings.Repeat(string(Mask), count)
+	})
+}
+
+// mergeMatches Merges overlapping matches ordered by index, extending lengths in every unit
+func mergeMatches(Matches []Match) []Match {
+	var ret = []Match{Matches[0]}
+
+	for _, match := range Matches[1:] {
+		var cur = &ret[len(ret)-1]
+		if match.Index >= cur.Index+cur.Length {
+			ret = append(ret, match)
+			continue
+		}
+
+		if end := match.Index + match.Length; end > cur.Index+cur.Length {
+			cur.Length = end - cur.Index
+			cur.RuneLength = match.RuneIndex + match.RuneLength - cur.RuneIndex
+			cur.UTF16Length = match.UTF16Index + match.UTF16Length - cur.UTF16Index
+		}
+	}
+
+	return ret
+}
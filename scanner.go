@@ -0,0 +1,222 @@
+package confusablematcher
+
+import (
+	"io"
+	"unicode/utf8"
+)
+
+// Scanner finds matches in a stream written to it in chunks, created by `Matcher.NewScanner`.
+// Only as much input as the longest possible match is kept in memory, so matches have to be limited with `Options.MaxLength`.
+// Scanner must not be used from multiple goroutines at the same time.
+type Scanner struct {
+	m       *Matcher
+	needles *Needles
+	options Options
+	emit    func(Match) error
+	// window Number of bytes the longest match can take
+	window int
+	buf    []byte
+	// base Offsets of `buf[0]` in the stream
+	base      Match
+	next      []int
+	emitted   int
+	closed    bool
+	completed bool
+}
+
+// NewScanner Creates a scanner finding every match of any of the needles in a stream, like `FindAny` would in the whole stream
+//
+// Parameters:
+//
+// - `Needles` : Needles returned by `CompileNeedles`, which must stay open while the scanner is used
+// - `Options` : Search options. `MaxLength` is required, as it decides how much input is kept for matches spanning chunks.
+// `StartIndex`, `Direction` and `Boundary` are not supported, as boundaries may depend on input the scanner no longer keeps.
+// `Max` limits matches in stream order.
+// - `Emit` : Called with every match, with offsets counted from the start of the stream, in order of index and then needle.
+// Error returned by it is returned from `Write` and `Close`.
+//
+// Returns:
+//
+// - Scanner to write the stream to, `Close` must be called after the last chunk
+// - `ErrMaxLengthRequired` if `Options.MaxLength` is not set, or `ErrUnsupportedOption` if unsupported option is set
+func (m *Matcher) NewScanner(Needles *Needles, Options Options, Emit func(Match) error) (*Scanner, error) {
+	if Options.MaxLength <= 0 {
+		return nil, ErrMaxLengthRequired
+	}
+	if Options.StartIndex != 0 || Options.Direction != Forward || Options.Boundary != nil {
+		return nil, ErrUnsupportedOption
+	}
+
+	var window = Options.MaxLength
+	switch Options.Unit {
+	case Runes:
+		window *= utf8.UTFMax
+	case UTF16:
+		// Runes outside of basic multilingual plane take 4 bytes and 2 units, others at most 3 bytes and 1 unit
+		window *= 3
+	}
+
+	return &Scanner{
+		m:       m,
+		needles: Needles,
+		options: Options,
+		emit:    Emit,
+		window:  window,
+		next:    make([]int, len(Needles.needles)),
+	}, nil
+}
+
+// Write Adds a chunk of the stream and emits matches which are not affected by following chunks
+func (s *Scanner) Write(P []byte) (int, error) {
+	if s.closed {
+		return 0, ErrClosed
+	}
+
+	s.buf = append(s.buf, P...)
+	return len(P), s.scan(false)
+}
+
+// ReadFrom Writes the whole reader to the scanner. `Close` still has to be called afterwards.
+func (s *Scanner) ReadFrom(R io.Reader) (int64, error) {
+	var chunk = make([]byte, 32*1024)
+	var total int64
+	for {
+		n, err := R.Read(chunk)
+		if n > 0 {
+			total += int64(n)
+			if _, werr := s.Write(chunk[:n]); werr != nil {
+				return total, werr
+			}
+		}
+		if err == io.EOF {
+			return total, nil
+		}
+		if err != nil {
+			return total, err
+		}
+	}
+}
+
+// Close Emits remaining matches at the end of the stream. Writing afterwards returns `ErrClosed`, calling `Close` again does nothing.
+func (s *Scanner) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return s.scan(true)
+}
+
+// scan Emits matches starting at positions which are followed by at least `window` bytes, or all of them at the end of the stream
+func (s *Scanner) scan(Final bool) error {
+	if s.completed {
+		s.buf = s.buf[:0]
+		return nil
+	}
+
+	var last = len(s.buf) - s.window
+	if Final {
+		last = len(s.buf)
+	}
+	if last < 0 {
+		return nil
+	}
+
+	snap, err := s.m.Snapshot()
+	if err != nil {
+		return err
+	}
+	defer snap.Release()
+
+	s.needles.lock.RLock()
+	if s.needles.closed {
+		s.needles.lock.RUnlock()
+		return ErrClosed
+	}
+	var needles = s.needles.needles
+	s.needles.lock.RUnlock()
+
+	var in = bytesString(s.buf)
+	var found []Match
+	for x, needle := range needles {
+		for {
+			var start = s.next[x] - s.base.Index
+			if start > last {
+				break
+			}
+
+			match, err := snap.indexAt(in, needle, s.options, start, nil)
+			if err != nil {
+				return err
+			}
+			if match.Index < start || (match.Index > last && !Final) {
+				// Nothing starts up to `last`, following chunks may still complete matches after it
+				s.next[x] = s.base.Index + last + 1
+				break
+			}
+
+			match.Needle = x
+			found = append(found, match)
+
+			if s.options.Overlapping || match.Length == 0 {
+				s.next[x] = s.base.Index + match.Index + 1
+			} else {
+				s.next[x] = s.base.Index + match.Index + match.Length
+			}
+		}
+	}
+
+	if err := s.emitAll(in, found); err != nil {
+		return err
+	}
+	s.discard(last)
+	return nil
+}
+
+func (s *Scanner) emitAll(In string, Found []Match) error {
+	sortMatches(Found)
+	fillOffsets(In, Found)
+
+	for _, match := range Found {
+		if s.options.Max > 0 && s.emitted >= s.options.Max {
+			s.completed = true
+			return nil
+		}
+
+		match.Index += s.base.Index
+		match.RuneIndex += s.base.RuneIndex
+		match.UTF16Index += s.base.UTF16Index
+		s.emitted++
+		if err := s.emit(match); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// discard Drops input before the next position any needle is searched from, keeping whole runes so that offsets in other units stay exact
+func (s *Scanner) discard(Last int) {
+	var cut = Last + 1
+	for _, el := range s.next {
+		if el-s.base.Index < cut {
+			cut = el - s.base.Index
+		}
+	}
+	if cut > len(s.buf) {
+		cut = len(s.buf)
+	}
+	for cut > 0 && cut < len(s.buf) && !utf8.RuneStart(s.buf[cut]) {
+		cut--
+	}
+	if cut <= 0 {
+		return
+	}
+
+	for x := 0; x < cut; {
+		r, width := utf8.DecodeRune(s.buf[x:cut])
+		s.base.RuneIndex += runeUnits(r, width, Runes)
+		s.base.UTF16Index += runeUnits(r, width, UTF16)
+		x += width
+	}
+	s.base.Index += cut
+	s.buf = s.buf[:copy(s.buf, s.buf[cut:])]
+}
@@ -0,0 +1,131 @@
+package confusablematcher
+
+// newSearch Returns tracer looking for first match of the needle ending anywhere, without recording segments.
+// Failed states do not depend on where the match started, so the tracer can be reused for every start index
+// unless match length is limited.
+func newSearch(Mappings *mappingTable, IgnoreList []string, In string, Contains string, MatchRepeating bool) *tracer {
+	return &tracer{
+		mappings:       Mappings,
+		ignoreList:     IgnoreList,
+		in:             In,
+		contains:       Contains,
+		matchRepeating: MatchRepeating,
+		end:            -1,
+		failed:         make(map[traceState]bool),
+	}
+}
+
+// limitLength Makes the tracer skip matches longer than `MaxLength` expressed in `U`
+func (t *tracer) limitLength(MaxLength int, U Unit) {
+	t.maxLength = MaxLength
+	t.unit = U
+}
+
+// matchAt Returns length of the match starting at `Start`, -1 if there is none
+func (t *tracer) matchAt(Start int) int {
+	t.start = Start
+	if t.boundary != nil && !t.startsAt(Start) {
+		return -1
+	}
+	if t.maxLength > 0 {
+		t.limit = Start + ByteOffset(t.in[Start:], t.maxLength, t.unit)
+		t.failed = make(map[traceState]bool)
+	}
+
+	if t.walk(Start, 0, "") {
+		return t.found - Start
+	}
+	return -1
+}
+
+// indexOf Returns index and length of first match starting at or after `StartIndex`, -1 and -1 if not found
+func (t *tracer) indexOf(StartIndex int) (int, int) {
+	if len(t.contains) == 0 {
+		return 0, 0
+	}
+	if StartIndex < 0 {
+		StartIndex = 0
+	}
+
+	for x := StartIndex; x < len(t.in) && !t.budget.stopped(); x++ {
+		if length := t.matchAt(x); length >= 0 {
+			return x, length
+		}
+	}
+	return -1, -1
+}
+
+// lastIndexOf Returns index and length of last match starting at or after `StartIndex`, -1 and -1 if not found
+func (t *tracer) lastIndexOf(StartIndex int) (int, int) {
+	if len(t.contains) == 0 {
+		return 0, 0
+	}
+	if StartIndex < 0 {
+		StartIndex = 0
+	}
+
+	for x := len(t.in) - 1; x >= StartIndex && !t.budget.stopped(); x-- {
+		if length := t.matchAt(x); length >= 0 {
+			return x, length
+		}
+	}
+	return -1, -1
+}
+
+// ignoreListFor Returns ignore list to search with, overridden by options if they carry one
+func ignoreListFor(Current []string, Options Options) []string {
+	if Options.IgnoreList == nil {
+		return Current
+	}
+	return Options.IgnoreList
+}
+
+// searchFirst Finds a match in Go, following documented semantics of the native matcher. Search gives up once `Limit` is exceeded, `nil` for no limit.
+func searchFirst(Mappings *mappingTable, IgnoreList []string, In string, Contains string, Options Options, StartIndex int, Limit *searchLimit) (Match, error) {
+	var t = newSearch(Mappings, ignoreListFor(IgnoreList, Options), In, Contains, Options.MatchRepeating)
+	t.limitLength(Options.MaxLength, Options.Unit)
+	t.boundary = Options.Boundary
+	t.budget = Limit
+
+	var ret Match
+	if Options.Direction == Backward {
+		ret.Index, ret.Length = t.lastIndexOf(StartIndex)
+	} else {
+		ret.Index, ret.Length = t.indexOf(StartIndex)
+	}
+	if Limit.stopped() {
+		return Match{Index: -1, Length: -1}, Limit.err
+	}
+	return ret, nil
+}
+
+// searchAll Finds matches of every needle in Go the same way native `findAny` helper does, grouped by needle
+func searchAll(Mappings *mappingTable, IgnoreList []string, In string, Needles []string, Options Options, StartIndex int) []Match {
+	IgnoreList = ignoreListFor(IgnoreList, Options)
+
+	var ret []Match
+	for needle, el := range Needles {
+		if Options.Max > 0 && len(ret) >= Options.Max {
+			break
+		}
+
+		var t = newSearch(Mappings, IgnoreList, In, el, Options.MatchRepeating)
+		t.limitLength(Options.MaxLength, Options.Unit)
+		t.boundary = Options.Boundary
+		for start := StartIndex; start <= len(In) && (Options.Max <= 0 || len(ret) < Options.Max); {
+			index, length := t.indexOf(start)
+			if index < start {
+				break
+			}
+
+			ret = append(ret, Match{Index: index, Length: length, Needle: needle})
+
+			if Options.Overlapping || length == 0 {
+				start = index + 1
+			} else {
+				start = index + length
+			}
+		}
+	}
+	return ret
+}
@@ -0,0 +1,347 @@
+package confusablematcher
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// Snapshot confusable matcher mappings which can be built separately and published into a `Matcher` with `Swap`.
+// Snapshot never changes once it is handed out: mapping and ignore list changes of a matcher publish a changed copy
+// while it is held, so a snapshot returned by `Matcher.Snapshot` is a consistent view for as long as it is held.
+// Snapshot is reference counted and its native memory is freed once the last reference is released.
+// Snapshots which differ only in their ignore list share native matcher.
+type Snapshot struct {
+	engine *engine
+	refs   int32
+	lock   sync.RWMutex
+}
+
+// NewSnapshot Builds new confusable matcher mappings without publishing them anywhere.
+// Returned snapshot holds one reference which is either handed over to a matcher with `Swap` or dropped with `Release`.
+//
+// Parameters:
+//
+// - `InputMap` : Input key to value mapping
+// - `AddDefaultValues` : Whether to add default values or not ([a-z] -> [A-Z], [A-Z] -> [A-Z], [0-9] -> [0-9])
+//
+// Returns:
+//
+// - Snapshot with an empty ignore list
+// - `ErrInitFailed` if native matcher could not be constructed
+func NewSnapshot(InputMap []KeyValue, AddDefaultValues bool) (*Snapshot, error) {
+	e, err := newEngine(InputMap, AddDefaultValues)
+	if err != nil {
+		return nil, err
+	}
+	return newSnapshot(e), nil
+}
+
+func newSnapshot(e *engine) *Snapshot {
+	var s = &Snapshot{engine: e, refs: 1}
+	runtime.SetFinalizer(s, (*Snapshot).finalize)
+	return s
+}
+
+// clone Returns a copy with its own native matcher, whose mappings can be changed before it is published.
+// The copy holds one reference, same as a snapshot returned by `NewSnapshot`.
+func (s *Snapshot) clone() (*Snapshot, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.engine == nil {
+		return nil, ErrClosed
+	}
+
+	// Mappings are listed in the order native matcher tries them, so the copy matches the same way
+	e, err := newEngine(s.engine.mappings.all(), false)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.setIgnoreList(s.engine.ignore); err != nil {
+		e.free()
+		return nil, err
+	}
+	return newSnapshot(e), nil
+}
+
+// withIgnoreList Returns a copy sharing native matcher with this snapshot and searching with another ignore list.
+// The copy holds one reference, same as a snapshot returned by `NewSnapshot`.
+func (s *Snapshot) withIgnoreList(In []string) (*Snapshot, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.engine == nil {
+		return nil, ErrClosed
+	}
+
+	e, err := s.engine.withIgnoreList(In)
+	if err != nil {
+		return nil, err
+	}
+	return newSnapshot(e), nil
+}
+
+// exclusive Reports whether the snapshot and its native matcher are used by a single holder of a reference only
+func (s *Snapshot) exclusive() bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.engine != nil && atomic.LoadInt32(&s.refs) == 1 && !s.engine.shared()
+}
+
+func (s *Snapshot) acquire() {
+	atomic.AddInt32(&s.refs, 1)
+}
+
+// Release Drops a reference to the snapshot. Native memory is freed when the last reference is dropped,
+// after which operations on the snapshot return `ErrClosed`.
+func (s *Snapshot) Release() {
+	if atomic.AddInt32(&s.refs, -1) != 0 {
+		return
+	}
+
+	runtime.SetFinalizer(s, nil)
+	s.free()
+}
+
+func (s *Snapshot) finalize() {
+	reportLeak("Snapshot")
+	s.free()
+}
+
+func (s *Snapshot) free() {
+	s.lock.Lock()
+	{
+		if s.engine != nil {
+			s.engine.free()
+			s.engine = nil
+		}
+	}
+	s.lock.Unlock()
+}
+
+// ignoreList Returns ignore list of the snapshot, which must not be modified
+func (s *Snapshot) ignoreList() []string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.engine == nil {
+		return nil
+	}
+	return s.engine.ignore
+}
+
+// IndexOf Performs an indexOf operation on this snapshot. See `Matcher.IndexOf`.
+func (s *Snapshot) IndexOf(In string, Contains string, MatchRepeating bool, StartIndex int) (int, int, error) {
+	match, err := s.indexOf(In, Contains, Options{MatchRepeating: MatchRepeating, StartIndex: StartIndex})
+	return match.Index, match.Length, err
+}
+
+// indexOf Returns first or last match with byte offsets only
+func (s *Snapshot) indexOf(In string, Contains string, Options Options) (Match, error) {
+	return s.indexAt(In, Contains, Options, ByteOffset(In, Options.StartIndex, Options.Unit), nil)
+}
+
+// indexAt Returns first or last match with byte offsets only, `StartIndex` is in bytes regardless of `Options.Unit`.
+// Search in Go gives up once `Limit` is exceeded, `nil` for no limit.
+func (s *Snapshot) indexAt(In string, Contains string, Options Options, StartIndex int, Limit *searchLimit) (Match, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.engine == nil {
+		return Match{Index: -1, Length: -1}, ErrClosed
+	}
+
+	match, err := s.engine.indexOf(In, Contains, Options, StartIndex, Limit)
+	if err != nil {
+		return Match{Index: -1, Length: -1}, err
+	}
+	return match, nil
+}
+
+// FindAll Finds every match of the needle in input string using a single native call. See `Matcher.FindAll`.
+func (s *Snapshot) FindAll(In string, Contains string, Options Options) ([]Match, error) {
+	return s.findAny(In, []string{Contains}, Options)
+}
+
+// FindAny Finds every match of any of the needles in input string using a single native call. See `Matcher.FindAny`.
+func (s *Snapshot) FindAny(In string, Needles *Needles, Options Options) ([]Match, error) {
+	Needles.lock.RLock()
+	defer Needles.lock.RUnlock()
+
+	if Needles.closed {
+		return nil, ErrClosed
+	}
+
+	return s.findAny(In, Needles.needles, Options)
+}
+
+func (s *Snapshot) findAny(In string, Needles []string, Options Options) ([]Match, error) {
+	var startIndex = ByteOffset(In, Options.StartIndex, Options.Unit)
+
+	// Last matches are only known once all of them are found
+	var max = Options.Max
+	if Options.Direction == Backward {
+		Options.Max = 0
+	}
+
+	var ret []Match
+	var err error
+	s.lock.RLock()
+	{
+		if s.engine == nil {
+			s.lock.RUnlock()
+			return nil, ErrClosed
+		}
+		ret, err = s.engine.findAny(In, Needles, Options, startIndex)
+	}
+	s.lock.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	sortMatches(ret)
+	if Options.Direction == Backward {
+		if max > 0 && len(ret) > max {
+			ret = ret[len(ret)-max:]
+		}
+		for x, y := 0, len(ret)-1; x < y; x, y = x+1, y-1 {
+			ret[x], ret[y] = ret[y], ret[x]
+		}
+	}
+	fillOffsets(In, ret)
+	return ret, nil
+}
+
+// Explain Finds a match of the needle and describes how it was matched. See `Matcher.Explain`.
+func (s *Snapshot) Explain(In string, Contains string, Options Options) (*Explanation, error) {
+	var startIndex = ByteOffset(In, Options.StartIndex, Options.Unit)
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.engine == nil {
+		return nil, ErrClosed
+	}
+
+	match, err := s.engine.indexOf(In, Contains, Options, startIndex, nil)
+	if err != nil {
+		return nil, err
+	}
+	if match.Index < 0 {
+		return nil, nil
+	}
+
+	segments, ok := s.engine.explain(In, Contains, Options, match.Index, match.Length)
+	if !ok {
+		return nil, ErrNoExplanation
+	}
+	return &Explanation{match.Index, match.Length, segments}, nil
+}
+
+// Mappings Returns mappings of this snapshot. See `Matcher.Mappings`.
+func (s *Snapshot) Mappings() []KeyValue {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.engine == nil {
+		return nil
+	}
+	return s.engine.mappings.all()
+}
+
+// MappingsForKey Returns values mapped to the key in this snapshot. See `Matcher.MappingsForKey`.
+func (s *Snapshot) MappingsForKey(Key string) []string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.engine == nil {
+		return nil
+	}
+	return s.engine.mappings.forKey(Key)
+}
+
+// Len Returns number of mappings in this snapshot. See `Matcher.Len`.
+func (s *Snapshot) Len() int {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.engine == nil {
+		return 0
+	}
+	return s.engine.mappings.count
+}
+
+func (s *Snapshot) addMapping(Key string, Value string, CheckValueDuplicate bool) error {
+	var ret MappingResponse
+	s.lock.Lock()
+	{
+		if s.engine == nil {
+			s.lock.Unlock()
+			return ErrClosed
+		}
+		ret = s.engine.addMapping(Key, Value, CheckValueDuplicate)
+	}
+	s.lock.Unlock()
+
+	return mappingError(Key, Value, ret)
+}
+
+func (s *Snapshot) removeMapping(Key string, Value string) error {
+	var ret bool
+	s.lock.Lock()
+	{
+		if s.engine == nil {
+			s.lock.Unlock()
+			return ErrClosed
+		}
+		ret = s.engine.removeMapping(Key, Value)
+	}
+	s.lock.Unlock()
+
+	if !ret {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *Snapshot) addMappings(Mappings []KeyValue, CheckValueDuplicate bool, AllOrNothing bool) ([]MappingResponse, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.engine == nil {
+		return nil, ErrClosed
+	}
+
+	if AllOrNothing {
+		var ret = s.engine.mappings.predictAdd(Mappings, CheckValueDuplicate)
+		for x, el := range ret {
+			if el != Success {
+				return ret, mappingError(Mappings[x].Key, Mappings[x].Value, el)
+			}
+		}
+	}
+
+	return s.engine.addMappings(Mappings, CheckValueDuplicate), nil
+}
+
+func (s *Snapshot) removeMappings(Mappings []KeyValue, AllOrNothing bool) ([]bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.engine == nil {
+		return nil, ErrClosed
+	}
+
+	if AllOrNothing {
+		var ret = s.engine.mappings.predictRemove(Mappings)
+		for _, el := range ret {
+			if !el {
+				return ret, ErrNotFound
+			}
+		}
+	}
+
+	return s.engine.removeMappings(Mappings), nil
+}
@@ -0,0 +1,119 @@
+package confusablematcher
+
+import "fmt"
+
+type txOp struct {
+	remove              bool
+	mapping             KeyValue
+	checkValueDuplicate bool
+}
+
+// Tx batch of mapping and ignore list changes applied to a matcher at once, created by `Matcher.Begin`.
+// Changes are only collected until `Commit`, Tx must not be used from multiple goroutines at the same time.
+type Tx struct {
+	m          *Matcher
+	ops        []txOp
+	ignoreList []string
+	setIgnore  bool
+	done       bool
+}
+
+// Begin Starts collecting changes which are applied together by `Tx.Commit`
+func (m *Matcher) Begin() *Tx {
+	return &Tx{m: m}
+}
+
+// AddMapping Queues adding a key to value mapping. See `Matcher.AddMapping`.
+func (tx *Tx) AddMapping(Key string, Value string, CheckValueDuplicate bool) {
+	tx.ops = append(tx.ops, txOp{false, KeyValue{Key, Value}, CheckValueDuplicate})
+}
+
+// RemoveMapping Queues removing a key to value mapping. See `Matcher.RemoveMapping`.
+func (tx *Tx) RemoveMapping(Key string, Value string) {
+	tx.ops = append(tx.ops, txOp{true, KeyValue{Key, Value}, false})
+}
+
+// SetIgnoreList Queues replacing the ignore list. See `Matcher.SetIgnoreList`.
+func (tx *Tx) SetIgnoreList(In []string) {
+	tx.ignoreList = append([]string(nil), In...)
+	tx.setIgnore = true
+}
+
+// Rollback Discards queued changes. Calling it after `Commit` does nothing.
+func (tx *Tx) Rollback() {
+	tx.done = true
+	tx.ops = nil
+	tx.ignoreList = nil
+}
+
+// Commit Validates queued changes in order against matcher's current mappings and publishes a snapshot with all of them,
+// or changes nothing if any would fail. Searches see either none or all of the changes.
+// The transaction is finished afterwards whether it succeeded or not.
+//
+// Returns:
+//
+// - `*MappingError` of first mapping which would not be added, or `ErrNotFound` if a mapping to remove would not exist,
+// in which case nothing was changed
+// - `ErrTxDone` if transaction was already committed or rolled back, or other error if operation could not be performed
+func (tx *Tx) Commit() error {
+	if tx.done {
+		return ErrTxDone
+	}
+	defer tx.Rollback()
+
+	if tx.m == nil {
+		return ErrClosed
+	}
+
+	// Ignore list alone is changed without building native matcher
+	if len(tx.ops) == 0 && tx.setIgnore {
+		var list = tx.ignoreList
+		return tx.m.updateIgnoreList(func([]string) []string {
+			return list
+		})
+	}
+
+	var ops = tx.ops
+	return tx.m.update(func(Next *Snapshot) error {
+		// Next snapshot is either not published yet or held by the matcher only, nothing else uses it
+		var e = Next.engine
+
+		var p = e.mappings.predictor()
+		for _, el := range ops {
+			if el.remove {
+				if !p.remove(el.mapping.Key, el.mapping.Value) {
+					return fmt.Errorf("%w (key %q, value %q)", ErrNotFound, el.mapping.Key, el.mapping.Value)
+				}
+			} else if res := p.add(el.mapping.Key, el.mapping.Value, el.checkValueDuplicate); res != Success {
+				return mappingError(el.mapping.Key, el.mapping.Value, res)
+			}
+		}
+
+		if tx.setIgnore {
+			if err := e.setIgnoreList(tx.ignoreList); err != nil {
+				return err
+			}
+		}
+
+		// Consecutive changes of the same kind are applied in one native call
+		for len(ops) != 0 {
+			var n = 1
+			for n < len(ops) && ops[n].remove == ops[0].remove && ops[n].checkValueDuplicate == ops[0].checkValueDuplicate {
+				n++
+			}
+
+			var batch = make([]KeyValue, n)
+			for x := range batch {
+				batch[x] = ops[x].mapping
+			}
+			if ops[0].remove {
+				e.removeMappings(batch)
+			} else {
+				e.addMappings(batch, ops[0].checkValueDuplicate)
+			}
+			ops = ops[n:]
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,103 @@
+package confusablematcher
+
+import "unicode/utf8"
+
+// Unit unit in which string offsets are expressed
+type Unit int
+
+const (
+	// Bytes UTF-8 bytes, the unit of Go string indexing
+	Bytes Unit = 0
+	// Runes Unicode code points
+	Runes Unit = 1
+	// UTF16 UTF-16 code units, as used by JavaScript, Java and C#
+	UTF16 Unit = 2
+)
+
+// runeUnits Returns how many units a rune of specified UTF-8 width takes
+func runeUnits(R rune, Width int, U Unit) int {
+	switch U {
+	case Runes:
+		return 1
+	case UTF16:
+		if R >= 0x10000 && Width == 4 {
+			return 2
+		}
+		return 1
+	}
+	return Width
+}
+
+// ByteOffset Converts offset expressed in specified unit into byte offset.
+// Offset pointing into the middle of a rune is moved to the start of next rune,
+// offset past the end of string stays past the end by the same amount.
+//
+// Parameters:
+//
+// - `In` : Input string
+// - `Offset` : Offset in `U` units
+// - `U` : Unit of the offset
+//
+// Returns:
+//
+// - Byte offset
+func ByteOffset(In string, Offset int, U Unit) int {
+	if U == Bytes || Offset <= 0 {
+		return Offset
+	}
+
+	var units = 0
+	for x := 0; x < len(In); {
+		if units >= Offset {
+			return x
+		}
+		r, width := utf8.DecodeRuneInString(In[x:])
+		units += runeUnits(r, width, U)
+		x += width
+	}
+
+	return len(In) + Offset - units
+}
+
+// fillOffsets Fills rune and UTF-16 offsets of matches from their byte offsets. Matches ordered by index either way
+// take a single pass over input string and matched parts of it, other orders are still handled but slower.
+func fillOffsets(In string, Matches []Match) {
+	var reversed = len(Matches) > 1 && Matches[0].Index > Matches[len(Matches)-1].Index
+
+	var start unitCounter
+	for i := range Matches {
+		var match = &Matches[i]
+		if reversed {
+			match = &Matches[len(Matches)-1-i]
+		}
+		if match.Index < start.pos {
+			start = unitCounter{}
+		}
+
+		start.advance(In, match.Index)
+		var end = start
+		end.advance(In, match.Index+match.Length)
+
+		match.RuneIndex = start.runes
+		match.RuneLength = end.runes - start.runes
+		match.UTF16Index = start.utf16
+		match.UTF16Length = end.utf16 - start.utf16
+	}
+}
+
+// unitCounter Counts runes and UTF-16 code units of input string up to a byte offset
+type unitCounter struct {
+	pos, x, runes, utf16 int
+}
+
+// advance Counts units up to byte offset `Pos`, which must not be before the previous one.
+// Rune which `Pos` points into the middle of is counted whole.
+func (c *unitCounter) advance(In string, Pos int) {
+	c.pos = Pos
+	for c.x < Pos && c.x < len(In) {
+		r, width := utf8.DecodeRuneInString(In[c.x:])
+		c.runes++
+		c.utf16 += runeUnits(r, width, UTF16)
+		c.x += width
+	}
+}